@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/ppp3ppj/notes-bubbletea-cli/tui"
+)
+
+func runAttach(args []string) {
+	flags := flag.NewFlagSet("attach", flag.ExitOnError)
+	list := flags.String("list", "", "list the attachments of the note with this id")
+	open := flags.Int("open", 0, "open the attachment with this id with the system opener")
+	prune := flags.Bool("prune", false, "remove attachments of deleted notes and unused files")
+	flags.Parse(args)
+
+	store, _ := openStore()
+
+	switch {
+	case *list != "":
+		listAttachments(store, *list)
+	case *open != 0:
+		a, ok, err := store.AttachmentById(*open)
+		if err != nil {
+			log.Fatalf("unable to read attachment: %v", err)
+		}
+		if !ok {
+			log.Fatalf("no attachment with id %d", *open)
+		}
+		if err := store.OpenAttachment(a); err != nil {
+			log.Fatalf("unable to open %s: %v", a.Name, err)
+		}
+	case *prune:
+		removed, err := store.PruneAttachments()
+		if err != nil {
+			log.Fatalf("unable to prune attachments: %v", err)
+		}
+		fmt.Printf("removed %d unused attachments\n", removed)
+	default:
+		if flags.NArg() < 2 {
+			log.Fatal("attach needs a note id and at least one file")
+		}
+		noteId := flags.Arg(0)
+		for _, path := range flags.Args()[1:] {
+			a, err := store.AddAttachment(noteId, path)
+			if err != nil {
+				log.Fatalf("unable to attach %s: %v", path, err)
+			}
+			fmt.Printf("%d\t%s\n", a.Id, a)
+		}
+	}
+}
+
+func listAttachments(store *tui.Store, noteId string) {
+	attachments, err := store.Attachments(noteId)
+	if err != nil {
+		log.Fatalf("unable to list attachments: %v", err)
+	}
+	for _, a := range attachments {
+		fmt.Printf("%d\t%s\t%s\n", a.Id, a, a.AddedAt.Local().Format("2006-01-02 15:04"))
+	}
+}
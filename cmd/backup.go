@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ppp3ppj/notes-bubbletea-cli/tui"
+)
+
+func runBackup(args []string) {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	dir := flags.String("dir", "", "backup folder, overrides backup_dir from the config")
+	keepLast := flags.Int("keep-last", -1, "newest backups to keep, overrides backup_keep_last")
+	keepDaily := flags.Int("keep-daily", -1, "days to keep one backup of, overrides backup_keep_daily")
+	list := flags.Bool("list", false, "list the backups instead of making one")
+	flags.Parse(args)
+
+	store, config := openStore()
+
+	policy := config.BackupPolicy()
+	if *dir != "" {
+		policy.Dir = *dir
+	}
+	if *keepLast >= 0 {
+		policy.KeepLast = *keepLast
+	}
+	if *keepDaily >= 0 {
+		policy.KeepDaily = *keepDaily
+	}
+
+	if *list {
+		paths, err := tui.Backups(policy.Dir)
+		if err != nil {
+			log.Fatalf("unable to list backups: %v", err)
+		}
+		for _, path := range paths {
+			fmt.Println(path)
+		}
+		return
+	}
+
+	path, removed, err := backup(store, policy)
+	if err != nil {
+		log.Fatalf("unable to back up: %v", err)
+	}
+	fmt.Println("wrote", path)
+	for _, old := range removed {
+		fmt.Println("removed", old)
+	}
+}
+
+// backup writes a new backup and rotates the old ones
+func backup(store *tui.Store, policy tui.BackupPolicy) (string, []string, error) {
+	path, err := store.Backup(policy.Dir)
+	if err != nil {
+		return "", nil, err
+	}
+	removed, err := tui.RotateBackups(policy)
+	return path, removed, err
+}
+
+func runRestore(args []string) {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: notes restore <backup file>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *backend != tui.BackendSQLite {
+		log.Fatalf("restore needs the %s backend", tui.BackendSQLite)
+	}
+
+	if err := tui.Restore(flags.Arg(0), *dbPath); err != nil {
+		log.Fatalf("unable to restore: %v", err)
+	}
+	fmt.Printf("restored %s, the old database is %s.before-restore\n", flags.Arg(0), *dbPath)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ppp3ppj/notes-bubbletea-cli/tui"
+)
+
+const budgetUsage = `usage: notes budget [list|set|clear]
+
+  list                                     show budgets and logged time
+  set -project <name> -hours <n> -per week|month|total [-start YYYY-MM-DD] [-end YYYY-MM-DD]
+  clear -project <name>                    remove the budget of a project
+`
+
+func runBudget(args []string) {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	flags := flag.NewFlagSet("budget "+args[0], flag.ExitOnError)
+	projectName := flags.String("project", "", "project name")
+	hours := flags.Float64("hours", 0, "planned hours")
+	per := flags.String("per", tui.BudgetPerWeek, "week, month or total")
+	start := flags.String("start", "", "first day of the budget (YYYY-MM-DD)")
+	end := flags.String("end", "", "last day of the budget (YYYY-MM-DD)")
+	flags.Parse(args[1:])
+
+	store, _ := openStore()
+
+	switch args[0] {
+	case "list":
+		statuses, err := store.GetBudgetStatuses(time.Now().Truncate(24 * time.Hour))
+		if err != nil {
+			log.Fatalf("unable to get budgets: %v", err)
+		}
+		for _, status := range statuses {
+			warning := ""
+			if status.Over() {
+				warning = "  OVER BUDGET"
+			}
+			fmt.Printf("%-12s %3.0f%%  %s  (%s)%s\n",
+				status.Project.Name, status.Percent()*100, status, status.PeriodLabel(), warning)
+		}
+
+	case "set":
+		project := mustProject(store, *projectName)
+		budget := tui.Budget{Project: project, Hours: *hours, Period: *per}
+		budget.Start = parseDateFlag("start", *start)
+		budget.End = parseDateFlag("end", *end)
+		if err := store.SaveBudget(budget); err != nil {
+			log.Fatalf("unable to save budget: %v", err)
+		}
+
+	case "clear":
+		project := mustProject(store, *projectName)
+		if err := store.DeleteBudget(project.Id); err != nil {
+			log.Fatalf("unable to clear budget: %v", err)
+		}
+
+	default:
+		fmt.Fprint(os.Stderr, budgetUsage)
+		os.Exit(2)
+	}
+}
+
+func mustProject(store *tui.Store, name string) tui.Project {
+	project, err := store.GetProjectByName(name)
+	if err != nil {
+		log.Fatalf("unable to get project: %v", err)
+	}
+	if project.Id == 0 {
+		log.Fatalf("unknown project %q", name)
+	}
+	return project
+}
+
+// parseDateFlag reads an optional YYYY-MM-DD flag value
+func parseDateFlag(name, value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		log.Fatalf("invalid -%s: %v", name, err)
+	}
+	return date
+}
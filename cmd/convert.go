@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/ppp3ppj/notes-bubbletea-cli/tui"
+)
+
+func runConvert(args []string) {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	to := flags.String("to", tui.BackendMarkdown, "backend to copy into, markdown or sqlite")
+	flags.Parse(args)
+
+	if *to == *backend {
+		log.Fatalf("source and target are both %s, pick the source with -backend", *to)
+	}
+
+	src, _ := openStorage()
+	dst := newStorage(*to)
+	if err := dst.Init(); err != nil {
+		log.Fatalf("unable to init %s store: %v", *to, err)
+	}
+
+	n, err := tui.Convert(src, dst)
+	if err != nil {
+		log.Fatalf("unable to convert: %v", err)
+	}
+	fmt.Printf("copied %d notes from %s to %s\n", n, *backend, *to)
+}
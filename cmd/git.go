@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ppp3ppj/notes-bubbletea-cli/tui"
+)
+
+// repoFlags collects -repo, which may be given more than once
+type repoFlags []string
+
+func (r *repoFlags) String() string { return strings.Join(*r, ",") }
+
+func (r *repoFlags) Set(value string) error {
+	*r = append(*r, value)
+	return nil
+}
+
+func runFromGit(args []string) {
+	var repos repoFlags
+	flags := flag.NewFlagSet("from-git", flag.ExitOnError)
+	flags.Var(&repos, "repo", "local repository to read, repeat for more (default the current folder)")
+	date := flags.String("date", "", "day of the commits (YYYY-MM-DD), default today")
+	author := flags.String("author", "", "commit author, overrides git_author from the config")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: notes from-git [-repo path]... [-date YYYY-MM-DD] [-author me@example.com]")
+		fmt.Fprintln(os.Stderr, "the project of each repository comes from: notes map -kind git <path>=<project>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if len(repos) == 0 {
+		repos = repoFlags{"."}
+	}
+	day := time.Now().Truncate(24 * time.Hour)
+	if *date != "" {
+		day = parseDateFlag("date", *date)
+	}
+
+	store, config := openStore()
+	if *author == "" {
+		*author = config.GitAuthor
+	}
+
+	drafts, err := store.GitDrafts(repos, *author, day)
+	if err != nil {
+		log.Fatalf("unable to read commits: %v", err)
+	}
+	if len(drafts) == 0 {
+		fmt.Println("no new commits on", day.Format("2006-01-02"))
+		return
+	}
+
+	saved, skipped, err := tui.ReviewDrafts(store, drafts)
+	if err != nil {
+		log.Fatalf("unable to review drafts: %v", err)
+	}
+	fmt.Printf("saved %d, skipped %d of %d drafts\n", saved, skipped, len(drafts))
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ppp3ppj/notes-bubbletea-cli/tui"
+)
+
+func runExport(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "ics", "output format, only ics for now")
+	from := flags.String("from", "", "first day (YYYY-MM-DD), default 30 days ago")
+	to := flags.String("to", "", "last day (YYYY-MM-DD), default today")
+	out := flags.String("o", "", "output file, default stdout")
+	flags.Parse(args)
+
+	if *format != "ics" {
+		log.Fatalf("unsupported format %q", *format)
+	}
+
+	store, _ := openStorage()
+
+	toDate := time.Now().Truncate(24 * time.Hour)
+	fromDate := toDate.AddDate(0, 0, -30)
+	if *from != "" {
+		fromDate = parseDateFlag("from", *from)
+	}
+	if *to != "" {
+		toDate = parseDateFlag("to", *to)
+	}
+
+	notes, err := store.GetNotesByDateRange(fromDate, toDate)
+	if err != nil {
+		log.Fatalf("unable to get notes: %v", err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("unable to create %s: %v", *out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := tui.ExportICS(w, notes); err != nil {
+		log.Fatalf("unable to export: %v", err)
+	}
+}
+
+func runImportICS(args []string) {
+	flags := flag.NewFlagSet("import-ics", flag.ExitOnError)
+	from := flags.String("from", "", "skip events before this day (YYYY-MM-DD)")
+	to := flags.String("to", "", "skip events after this day (YYYY-MM-DD)")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: notes import-ics [-from YYYY-MM-DD] [-to YYYY-MM-DD] <file.ics>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		log.Fatalf("unable to open calendar: %v", err)
+	}
+	defer f.Close()
+
+	events, err := tui.ParseICS(f)
+	if err != nil {
+		log.Fatalf("unable to read calendar: %v", err)
+	}
+
+	store, _ := openStore()
+	imported, err := store.ImportICS(events, parseDateFlag("from", *from), parseDateFlag("to", *to))
+	if err != nil {
+		log.Fatalf("unable to import calendar: %v", err)
+	}
+	fmt.Printf("imported %d of %d events as draft notes\n", imported, len(events))
+}
+
+// runMap lists, sets or removes the pattern to project mappings used by imports
+func runMap(args []string) {
+	flags := flag.NewFlagSet("map", flag.ExitOnError)
+	kind := flags.String("kind", "ics", "mapping kind, ics or git")
+	remove := flags.Bool("d", false, "delete the mapping of the pattern")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: notes map [-kind ics|git] [pattern=project | -d pattern]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	store, _ := openStore()
+
+	switch {
+	case flags.NArg() == 0:
+		mappings, err := store.GetProjectMappings(*kind)
+		if err != nil {
+			log.Fatalf("unable to get mappings: %v", err)
+		}
+		for _, m := range mappings {
+			fmt.Printf("%s=%s\n", m.Pattern, m.ProjectName)
+		}
+
+	case *remove:
+		if err := store.DeleteProjectMapping(*kind, flags.Arg(0)); err != nil {
+			log.Fatalf("unable to delete mapping: %v", err)
+		}
+
+	default:
+		pattern, projectName, ok := strings.Cut(flags.Arg(0), "=")
+		if !ok || pattern == "" {
+			flags.Usage()
+			os.Exit(2)
+		}
+		project := mustProject(store, projectName)
+		mapping := tui.ProjectMapping{Kind: *kind, Pattern: pattern, ProjectName: project.Name}
+		if err := store.SaveProjectMapping(mapping); err != nil {
+			log.Fatalf("unable to save mapping: %v", err)
+		}
+	}
+}
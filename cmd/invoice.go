@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ppp3ppj/notes-bubbletea-cli/tui"
+)
+
+func runInvoice(args []string) {
+	flags := flag.NewFlagSet("invoice", flag.ExitOnError)
+	projectName := flags.String("project", "", "project to invoice")
+	from := flags.String("from", "", "first day (YYYY-MM-DD), default first day of last month")
+	to := flags.String("to", "", "last day (YYYY-MM-DD), default last day of last month")
+	rounding := flags.String("round", "", "round the time of each note, e.g. 15m (overrides invoice_rounding)")
+	mode := flags.String("mode", "", "rounding mode: up, down or nearest (overrides invoice_rounding_mode)")
+	out := flags.String("out", "", "directory for the invoice files (overrides invoice_dir)")
+	draft := flags.Bool("draft", false, "print the invoice without numbering it or marking notes")
+	flags.Parse(args)
+
+	store, config := openStore()
+	project := mustProject(store, *projectName)
+
+	firstOfMonth := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-time.Now().UTC().Day())
+	fromDate := firstOfMonth.AddDate(0, -1, 0)
+	toDate := firstOfMonth.AddDate(0, 0, -1)
+	if *from != "" {
+		fromDate = parseDateFlag("from", *from)
+	}
+	if *to != "" {
+		toDate = parseDateFlag("to", *to)
+	}
+
+	if *rounding == "" {
+		*rounding = config.InvoiceRounding
+	}
+	if *mode == "" {
+		*mode = config.InvoiceRoundingMode
+	}
+	var roundTo time.Duration
+	if *rounding != "" {
+		var err error
+		if roundTo, err = time.ParseDuration(*rounding); err != nil {
+			log.Fatalf("invalid rounding: %v", err)
+		}
+	}
+
+	invoice, err := store.DraftInvoice(project, fromDate, toDate, tui.Rounding{To: roundTo, Mode: *mode})
+	if err != nil {
+		log.Fatalf("unable to build invoice: %v", err)
+	}
+	invoice.Currency = config.Currency
+
+	if len(invoice.Groups) == 0 {
+		log.Fatalf("no billable notes for %s between %s and %s", project.Name, fromDate.Format("2006-01-02"), toDate.Format("2006-01-02"))
+	}
+
+	if *draft {
+		markdown, err := invoice.Markdown()
+		if err != nil {
+			log.Fatalf("unable to render invoice: %v", err)
+		}
+		fmt.Print(markdown)
+		return
+	}
+
+	if err := store.SaveInvoice(&invoice); err != nil {
+		log.Fatalf("unable to save invoice: %v", err)
+	}
+
+	dir := *out
+	if dir == "" {
+		dir = config.InvoiceDir
+	}
+	if dir == "" {
+		dir = "./invoices"
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Fatalf("unable to create %s: %v", dir, err)
+	}
+
+	markdown, err := invoice.Markdown()
+	if err != nil {
+		log.Fatalf("unable to render invoice: %v", err)
+	}
+	html, err := invoice.HTML()
+	if err != nil {
+		log.Fatalf("unable to render invoice: %v", err)
+	}
+
+	base := filepath.Join(dir, "invoice-"+invoice.Number)
+	if err := os.WriteFile(base+".md", []byte(markdown), 0o644); err != nil {
+		log.Fatalf("unable to write invoice: %v", err)
+	}
+	if err := os.WriteFile(base+".html", []byte(html), 0o644); err != nil {
+		log.Fatalf("unable to write invoice: %v", err)
+	}
+
+	fmt.Printf("invoice %s: %.2f hours, %.2f %s\n%s.md\n%s.html\n",
+		invoice.Number, invoice.Hours, invoice.Total, invoice.Currency, base, base)
+}
+
+func runRate(args []string) {
+	flags := flag.NewFlagSet("rate", flag.ExitOnError)
+	projectName := flags.String("project", "", "project name, list the rates when empty")
+	categoryName := flags.String("category", "", "category overriding the project rate")
+	rate := flags.Float64("rate", 0, "hourly rate")
+	flags.Parse(args)
+
+	store, _ := openStore()
+
+	if *projectName == "" {
+		rates, err := store.GetRates()
+		if err != nil {
+			log.Fatalf("unable to get rates: %v", err)
+		}
+		for _, r := range rates {
+			category := r.Category.Name
+			if category == "" {
+				category = "(default)"
+			}
+			fmt.Printf("%-12s %-12s %10.2f\n", r.Project.Name, category, r.HourlyRate)
+		}
+		return
+	}
+
+	project := mustProject(store, *projectName)
+	r := tui.Rate{Project: project, HourlyRate: *rate}
+	if *categoryName != "" {
+		categories, err := store.GetCategoriesByProject(project.Id)
+		if err != nil {
+			log.Fatalf("unable to get categories: %v", err)
+		}
+		for _, c := range categories {
+			if c.Name == *categoryName {
+				r.Category = c
+			}
+		}
+		if r.Category.Id == 0 {
+			log.Fatalf("project %s has no category %q", project.Name, *categoryName)
+		}
+	}
+
+	if err := store.SaveRate(r); err != nil {
+		log.Fatalf("unable to save rate: %v", err)
+	}
+}
@@ -1,22 +1,149 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
+	"os"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/ppp3ppj/notes-bubbletea-cli/tui"
 )
 
+const usage = `usage: notes [-backend sqlite|markdown] [-db file] [-dir folder] [command]
+
+commands:
+  (none)     start the TUI
+  standup    print the standup report
+  budget     list and set project time budgets
+  rate       list and set hourly rates
+  invoice    bill the uninvoiced notes of a project
+  export     export notes as iCalendar (--format ics)
+  import-ics turn the events of an .ics file into draft notes
+  from-git   turn the day's commits into notes after a review (-repo path -date YYYY-MM-DD)
+  map        map import patterns to projects
+  convert    copy all notes into the other backend (-to markdown|sqlite)
+  backup     back up the database, safe while the TUI runs
+  restore    check a backup and swap it in as the database
+  passphrase encrypt note titles and bodies, or change the passphrase
+  serve      serve the web dashboard and the JSON API (-addr 127.0.0.1:8080)
+  sync       sync with another database (-dir shared-folder | -url http://peer:8080)
+  attach     attach files to a note (<note-id> <file>...), -list <note-id>, -open <id>, -prune
+  status     print a status line for shell prompts and tmux (-format "{today_total} / {target}")
+`
+
+var (
+    backend = flag.String("backend", tui.BackendSQLite, "storage backend, sqlite or markdown")
+    dbPath  = flag.String("db", tui.DefaultDBPath, "database file of the sqlite backend")
+    dirPath = flag.String("dir", tui.DefaultNotesDir, "folder of the markdown backend")
+)
+
 func main() {
-    store := &tui.Store{}
-    if err := store.Init(); err != nil {
-        log.Fatalf("unable to init store: %v", err)
+    flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+    flag.Parse()
+
+    if args := flag.Args(); len(args) > 0 {
+        switch args[0] {
+        case "standup":
+            runStandup(args[1:])
+        case "budget":
+            runBudget(args[1:])
+        case "rate":
+            runRate(args[1:])
+        case "invoice":
+            runInvoice(args[1:])
+        case "export":
+            runExport(args[1:])
+        case "import-ics":
+            runImportICS(args[1:])
+        case "from-git":
+            runFromGit(args[1:])
+        case "map":
+            runMap(args[1:])
+        case "convert":
+            runConvert(args[1:])
+        case "backup":
+            runBackup(args[1:])
+        case "restore":
+            runRestore(args[1:])
+        case "passphrase":
+            runPassphrase(args[1:])
+        case "serve":
+            runServe(args[1:])
+        case "sync":
+            runSync(args[1:])
+        case "status":
+            runStatus(args[1:])
+        case "attach":
+            runAttach(args[1:])
+        case "help", "-h", "--help":
+            fmt.Print(usage)
+        default:
+            fmt.Fprint(os.Stderr, usage)
+            os.Exit(2)
+        }
+        return
+    }
+
+    store, config := openStorage()
+
+    if sqlite, ok := store.(*tui.Store); ok && config.AutoBackup {
+        if _, _, err := backup(sqlite, config.BackupPolicy()); err != nil {
+            log.Printf("automatic backup failed: %v", err)
+        }
     }
 
-    m := tui.NewModel(store)
+    m := tui.NewModel(store, config)
 
     p := tea.NewProgram(m)
     if _, err := p.Run(); err != nil {
         log.Fatalf("unable to run tui: %v", err)
     }
 }
+
+func loadConfig() tui.Config {
+    config, err := tui.LoadConfig(tui.DefaultConfigPath)
+    if err != nil {
+        log.Fatalf("unable to load config: %v", err)
+    }
+    return config
+}
+
+// newStorage returns the uninitialised storage of the backend
+func newStorage(backend string) tui.Storage {
+    switch backend {
+    case tui.BackendSQLite:
+        return &tui.Store{Path: *dbPath}
+    case tui.BackendMarkdown:
+        return &tui.FileStore{Dir: *dirPath}
+    }
+    log.Fatalf("unknown backend %q, use %s or %s", backend, tui.BackendSQLite, tui.BackendMarkdown)
+    return nil
+}
+
+// openStorage opens the backend selected with -backend
+func openStorage() (tui.Storage, tui.Config) {
+    config := loadConfig()
+
+    storage := newStorage(*backend)
+    if err := storage.Init(); err != nil {
+        log.Fatalf("unable to init store: %v", err)
+    }
+
+    if store, ok := storage.(*tui.Store); ok {
+        unlock(store)
+    }
+
+    return storage, config
+}
+
+// openStore opens the SQLite backend for the commands only it supports
+func openStore() (*tui.Store, tui.Config) {
+    storage, config := openStorage()
+
+    store, ok := storage.(*tui.Store)
+    if !ok {
+        log.Fatalf("this command needs the %s backend", tui.BackendSQLite)
+    }
+    return store, config
+}
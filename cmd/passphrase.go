@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ppp3ppj/notes-bubbletea-cli/tui"
+)
+
+// passphraseEnv unlocks encrypted notes without a prompt, e.g. in scripts
+const passphraseEnv = "NOTES_PASSPHRASE"
+
+// unlock asks for the passphrase of encrypted notes before anything reads them
+func unlock(store *tui.Store) {
+	if !store.Locked() {
+		return
+	}
+
+	if passphrase, ok := os.LookupEnv(passphraseEnv); ok {
+		if err := store.Unlock(passphrase); err != nil {
+			log.Fatalf("unable to unlock with $%s: %v", passphraseEnv, err)
+		}
+		return
+	}
+
+	if err := tui.UnlockPrompt(store); err != nil {
+		log.Fatalf("unable to unlock: %v", err)
+	}
+}
+
+func runPassphrase(args []string) {
+	flags := flag.NewFlagSet("passphrase", flag.ExitOnError)
+	remove := flags.Bool("remove", false, "decrypt every note and turn encryption off")
+	flags.Parse(args)
+
+	store, _ := openStore()
+
+	passphrase := ""
+	if !*remove {
+		var err error
+		passphrase, err = tui.PromptPassphrase("New passphrase", func(p string) error {
+			if p == "" {
+				return errors.New("the passphrase must not be empty, use -remove to turn encryption off")
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatalf("unable to read passphrase: %v", err)
+		}
+		_, err = tui.PromptPassphrase("Repeat the new passphrase", func(p string) error {
+			if p != passphrase {
+				return errors.New("the passphrases do not match")
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatalf("unable to read passphrase: %v", err)
+		}
+	} else if !store.Encrypted() {
+		fmt.Println("notes are not encrypted")
+		return
+	}
+
+	if err := store.ChangePassphrase(passphrase); err != nil {
+		log.Fatalf("unable to change passphrase: %v", err)
+	}
+	if *remove {
+		fmt.Println("notes are decrypted, encryption is off")
+	} else {
+		fmt.Println("notes are encrypted with the new passphrase")
+	}
+}
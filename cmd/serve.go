@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ppp3ppj/notes-bubbletea-cli/tui"
+)
+
+func runServe(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", "127.0.0.1:8080", "address to listen on")
+	flags.Parse(args)
+
+	store, config := openStorage()
+
+	mux := http.NewServeMux()
+	mux.Handle("/", tui.NewDashboardHandler(store, config.DashboardPassword))
+	if config.APIToken != "" {
+		mux.Handle("/api/", tui.NewAPIHandler(store, config.APIToken))
+	} else {
+		log.Printf("the JSON API is off, set api_token in %s to turn it on", tui.DefaultConfigPath)
+	}
+	if config.DashboardPassword == "" {
+		log.Printf("the dashboard has no password, set dashboard_password in %s to ask for one", tui.DefaultConfigPath)
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Printf("serving the dashboard on http://%s/ and the API on http://%s/api/", *addr, *addr)
+	log.Fatal(server.ListenAndServe())
+}
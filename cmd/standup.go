@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/ppp3ppj/notes-bubbletea-cli/tui"
+)
+
+func runStandup(args []string) {
+	flags := flag.NewFlagSet("standup", flag.ExitOnError)
+	date := flags.String("date", "", "day of the standup (YYYY-MM-DD), default today")
+	templatePath := flags.String("template", "", "text/template file, overrides standup_template from the config")
+	render := flags.Bool("render", false, "style the markdown for the terminal")
+	flags.Parse(args)
+
+	store, config := openStorage()
+
+	day := time.Now().Truncate(24 * time.Hour)
+	if *date != "" {
+		day = parseDateFlag("date", *date)
+	}
+
+	if *templatePath == "" {
+		*templatePath = config.StandupTemplate
+	}
+
+	report, err := tui.StandupReport(store, day, *templatePath)
+	if err != nil {
+		log.Fatalf("unable to build standup: %v", err)
+	}
+
+	if *render {
+		if report, err = glamour.Render(report, "auto"); err != nil {
+			log.Fatalf("unable to render standup: %v", err)
+		}
+	}
+	fmt.Print(report)
+}
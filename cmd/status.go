@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ppp3ppj/notes-bubbletea-cli/tui"
+)
+
+func runStatus(args []string) {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	format := flags.String("format", "", "status template, overrides status_format from the config")
+	maxAge := flags.Duration("cache", tui.DefaultStatusMaxAge, "how long a cached status is reused, 0 reads the database every time")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: notes status [-format template] [-cache 30s]")
+		fmt.Fprintln(os.Stderr, "placeholders: {today_total} {target} {remaining} {notes} {drafts} {date} {running_timer}")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	config := loadConfig()
+	if *format == "" {
+		*format = config.StatusFormat
+	}
+	if *format == "" {
+		*format = tui.DefaultStatusFormat
+	}
+
+	// not openStorage: the database is only read and never unlocked
+	fields, err := tui.StatusFields(newStorage(*backend), config, *maxAge)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "notes status:", err)
+		os.Exit(1)
+	}
+	// empty placeholders, e.g. no running timer, leave no trailing space
+	fmt.Println(strings.TrimSpace(tui.RenderStatus(*format, fields)))
+}
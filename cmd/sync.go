@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/ppp3ppj/notes-bubbletea-cli/tui"
+)
+
+func runSync(args []string) {
+	flags := flag.NewFlagSet("sync", flag.ExitOnError)
+	dir := flags.String("dir", "", "shared folder to sync through, not with encrypted notes")
+	url := flags.String("url", "", "notes serve of the other machine, e.g. http://workstation:8080")
+	token := flags.String("token", "", "api_token of the other machine, defaults to api_token from the config")
+	flags.Parse(args)
+
+	var transport tui.SyncTransport
+	switch {
+	case *dir != "" && *url != "":
+		log.Fatal("sync needs either -dir or -url, not both")
+	case *dir != "":
+		transport = tui.DirTransport{Dir: *dir}
+	case *url != "":
+		transport = tui.HTTPTransport{URL: *url, Token: *token}
+	default:
+		log.Fatal("sync needs -dir or -url")
+	}
+
+	store, config := openStore()
+	if http, ok := transport.(tui.HTTPTransport); ok && http.Token == "" {
+		http.Token = config.APIToken
+		transport = http
+	}
+
+	report, err := store.Sync(transport)
+	if err != nil {
+		log.Fatalf("unable to sync: %v", err)
+	}
+
+	fmt.Printf("sent %d, received %d, deleted %d\n", report.Sent, report.Received, report.Deleted)
+	for _, conflict := range report.Conflicts {
+		fmt.Printf("conflict: %q %s, kept the %s version (%s)\n", conflict.Title, conflict.Reason, conflict.Kept, conflict.Id)
+	}
+}
@@ -0,0 +1,490 @@
+package tui
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// apiNote is the JSON form of a note. Project and Category are filled in
+// responses, requests name them by id. Date files a new note under a day.
+type apiNote struct {
+	Id         string    `json:"id"`
+	Title      string    `json:"title"`
+	Body       string    `json:"body"`
+	TotalTime  string    `json:"total_time"`
+	ProjectId  int       `json:"project_id"`
+	Project    string    `json:"project,omitempty"`
+	CategoryId int       `json:"category_id"`
+	Category   string    `json:"category,omitempty"`
+	Date       string    `json:"date,omitempty"`
+	Billable   *bool     `json:"billable,omitempty"`
+	Draft      bool      `json:"draft"`
+	InvoiceId  int       `json:"invoice_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type apiProject struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type apiCategory struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func toAPINote(note Note) apiNote {
+	billable := note.Billable
+	return apiNote{
+		Id:         note.Id,
+		Title:      note.Title,
+		Body:       note.Body,
+		TotalTime:  note.TotalTime,
+		ProjectId:  note.Project.Id,
+		Project:    note.Project.Name,
+		CategoryId: note.Category.Id,
+		Category:   note.Category.Name,
+		Date:       note.CreatedAt.UTC().Format(dateLayout),
+		Billable:   &billable,
+		Draft:      note.Draft,
+		InvoiceId:  note.InvoiceId,
+		CreatedAt:  note.CreatedAt,
+		UpdatedAt:  note.UpdatedAt,
+	}
+}
+
+// apiError is written as {"error": "..."} with its status code
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e apiError) Error() string {
+	return e.message
+}
+
+func badRequest(format string, args ...any) error {
+	return apiError{http.StatusBadRequest, fmt.Sprintf(format, args...)}
+}
+
+var errNotFound = apiError{http.StatusNotFound, "not found"}
+
+// api serves the notes, projects and categories of a store as JSON
+type api struct {
+	store Storage
+	token string
+	mu    sync.RWMutex // the markdown backend is not safe for concurrent writes
+}
+
+// NewAPIHandler returns the REST API below /api/. Every request needs the
+// header "Authorization: Bearer <token>".
+func NewAPIHandler(store Storage, token string) http.Handler {
+	a := &api{store: store, token: token}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/notes", a.read(a.listNotes))
+	mux.HandleFunc("POST /api/notes", a.write(a.createNote))
+	mux.HandleFunc("GET /api/notes/{id}", a.read(a.getNote))
+	mux.HandleFunc("PUT /api/notes/{id}", a.write(a.updateNote))
+	mux.HandleFunc("DELETE /api/notes/{id}", a.write(a.deleteNote))
+	mux.HandleFunc("GET /api/projects", a.read(a.listProjects))
+	mux.HandleFunc("POST /api/projects", a.write(a.createProject))
+	mux.HandleFunc("GET /api/projects/{id}", a.read(a.getProject))
+	mux.HandleFunc("GET /api/projects/{id}/categories", a.read(a.listCategories))
+	mux.HandleFunc("POST /api/projects/{id}/categories", a.write(a.addCategory))
+	if _, ok := store.(*Store); ok {
+		mux.HandleFunc("POST /api/sync", a.write(a.sync))
+	}
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	})
+
+	return a.authenticate(mux)
+}
+
+func (a *api) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || a.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="notes"`)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or wrong token"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type apiFunc func(r *http.Request) (int, any, error)
+
+func (a *api) read(f apiFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a.mu.RLock()
+		defer a.mu.RUnlock()
+		respond(w, f)(r)
+	}
+}
+
+func (a *api) write(f apiFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		respond(w, f)(r)
+	}
+}
+
+func respond(w http.ResponseWriter, f apiFunc) func(*http.Request) {
+	return func(r *http.Request) {
+		status, body, err := f(r)
+
+		var apiErr apiError
+		var conflict *ConflictError
+		var invalid invalidNoteError
+		switch {
+		case errors.As(err, &conflict):
+			writeJSON(w, http.StatusConflict, map[string]any{"error": err.Error(), "current": toAPINote(conflict.Theirs)})
+		case errors.As(err, &invalid):
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": invalid.Error()})
+		case errors.As(err, &apiErr):
+			writeJSON(w, apiErr.status, map[string]string{"error": apiErr.message})
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		case body == nil:
+			w.WriteHeader(status)
+		default:
+			writeJSON(w, status, body)
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func decodeJSON(r *http.Request, v any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return badRequest("invalid JSON: %v", err)
+	}
+	return nil
+}
+
+func pathId(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return 0, badRequest("invalid id %q", r.PathValue("id"))
+	}
+	return id, nil
+}
+
+func queryDate(r *http.Request, name string) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	date, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return time.Time{}, badRequest("%s must look like %s", name, dateLayout)
+	}
+	return date, nil
+}
+
+// listNotes answers ?date=, ?from=&to= and ?project=, without any of them the notes of today
+func (a *api) listNotes(r *http.Request) (int, any, error) {
+	var notes []Note
+
+	date, err := queryDate(r, "date")
+	if err != nil {
+		return 0, nil, err
+	}
+	from, err := queryDate(r, "from")
+	if err != nil {
+		return 0, nil, err
+	}
+	to, err := queryDate(r, "to")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	switch project := r.URL.Query().Get("project"); {
+	case project != "":
+		projectId, convErr := strconv.Atoi(project)
+		if convErr != nil {
+			return 0, nil, badRequest("invalid project %q", project)
+		}
+		notes, err = a.store.GetNotesByProject(projectId)
+	case !from.IsZero() || !to.IsZero():
+		if from.IsZero() || to.IsZero() || to.Before(from) {
+			return 0, nil, badRequest("from and to are both needed, from not after to")
+		}
+		notes, err = a.store.GetNotesByDateRange(from, to)
+	case !date.IsZero():
+		notes, err = a.store.GetNotesByDate(date)
+	default:
+		notes, err = a.store.GetNotesByDate(today())
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	sortNotes(notes, sortByCreated)
+	result := []apiNote{}
+	for _, note := range notes {
+		result = append(result, toAPINote(note))
+	}
+	return http.StatusOK, result, nil
+}
+
+func (a *api) getNote(r *http.Request) (int, any, error) {
+	note, err := a.store.GetNoteById(r.PathValue("id"))
+	if err != nil {
+		return 0, nil, err
+	}
+	if note.Id == "" {
+		return 0, nil, errNotFound
+	}
+	return http.StatusOK, toAPINote(note), nil
+}
+
+func (a *api) createNote(r *http.Request) (int, any, error) {
+	var in apiNote
+	if err := decodeJSON(r, &in); err != nil {
+		return 0, nil, err
+	}
+	if err := validateNote(a.store, in.Title, in.ProjectId, in.CategoryId); err != nil {
+		return 0, nil, err
+	}
+
+	day := today()
+	if in.Date != "" {
+		var err error
+		if day, err = time.Parse(dateLayout, in.Date); err != nil {
+			return 0, nil, badRequest("date must look like %s", dateLayout)
+		}
+	}
+
+	note := Note{
+		Id:        uuid.New().String(),
+		Title:     in.Title,
+		Body:      in.Body,
+		TotalTime: in.TotalTime,
+		CreatedAt: onDate(day, time.Now()),
+		Draft:     in.Draft,
+	}
+	if err := a.store.SaveNoteWithProject(note, in.ProjectId, in.CategoryId, day); err != nil {
+		return 0, nil, err
+	}
+	if in.Billable != nil && !*in.Billable {
+		if err := a.store.SetNoteBillable(note.Id, false); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	saved, err := a.store.GetNoteById(note.Id)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, toAPINote(saved), nil
+}
+
+// updateNote replaces the note, updated_at must be the value last read or
+// the answer is 409 with the current note. It is required so a client cannot
+// overwrite edits it has not seen.
+func (a *api) updateNote(r *http.Request) (int, any, error) {
+	existing, err := a.store.GetNoteById(r.PathValue("id"))
+	if err != nil {
+		return 0, nil, err
+	}
+	if existing.Id == "" {
+		return 0, nil, errNotFound
+	}
+
+	var in apiNote
+	if err := decodeJSON(r, &in); err != nil {
+		return 0, nil, err
+	}
+	if in.Id != "" && in.Id != existing.Id {
+		return 0, nil, badRequest("id does not match the URL")
+	}
+	if in.UpdatedAt.IsZero() {
+		return 0, nil, badRequest("updated_at is required, send the value of the last read")
+	}
+	if err := validateNote(a.store, in.Title, in.ProjectId, in.CategoryId); err != nil {
+		return 0, nil, err
+	}
+
+	note := existing
+	note.Title = in.Title
+	note.Body = in.Body
+	note.TotalTime = in.TotalTime
+	note.Draft = in.Draft
+	note.UpdatedAt = in.UpdatedAt
+	if err := a.store.SaveNoteWithProject(note, in.ProjectId, in.CategoryId, existing.CreatedAt); err != nil {
+		return 0, nil, err
+	}
+	if in.Billable != nil && *in.Billable != existing.Billable {
+		if err := a.store.SetNoteBillable(note.Id, *in.Billable); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	saved, err := a.store.GetNoteById(note.Id)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, toAPINote(saved), nil
+}
+
+func (a *api) deleteNote(r *http.Request) (int, any, error) {
+	note, err := a.store.GetNoteById(r.PathValue("id"))
+	if err != nil {
+		return 0, nil, err
+	}
+	if note.Id == "" {
+		return 0, nil, errNotFound
+	}
+	return http.StatusNoContent, nil, a.store.DeleteNote(note.Id)
+}
+
+func (a *api) listProjects(r *http.Request) (int, any, error) {
+	projects, err := a.store.GetProjects()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	result := []apiProject{}
+	for _, p := range projects {
+		result = append(result, apiProject{Id: p.Id, Name: p.Name, Description: p.Description})
+	}
+	return http.StatusOK, result, nil
+}
+
+func (a *api) getProject(r *http.Request) (int, any, error) {
+	id, err := pathId(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	project, err := a.store.GetProjectById(id)
+	if err != nil {
+		return 0, nil, err
+	}
+	if project.Id == 0 {
+		return 0, nil, errNotFound
+	}
+	return http.StatusOK, apiProject{Id: project.Id, Name: project.Name, Description: project.Description}, nil
+}
+
+func (a *api) createProject(r *http.Request) (int, any, error) {
+	var in apiProject
+	if err := decodeJSON(r, &in); err != nil {
+		return 0, nil, err
+	}
+	if strings.TrimSpace(in.Name) == "" {
+		return 0, nil, badRequest("name must not be empty")
+	}
+
+	existing, err := a.store.GetProjectByName(in.Name)
+	if err != nil {
+		return 0, nil, err
+	}
+	if existing.Id != 0 {
+		return 0, nil, apiError{http.StatusConflict, fmt.Sprintf("project %q exists", in.Name)}
+	}
+
+	if err := a.store.SaveProject(Project{Name: in.Name, Description: in.Description}); err != nil {
+		return 0, nil, err
+	}
+	project, err := a.store.GetProjectByName(in.Name)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, apiProject{Id: project.Id, Name: project.Name, Description: project.Description}, nil
+}
+
+func (a *api) listCategories(r *http.Request) (int, any, error) {
+	id, err := pathId(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	project, err := a.store.GetProjectById(id)
+	if err != nil {
+		return 0, nil, err
+	}
+	if project.Id == 0 {
+		return 0, nil, errNotFound
+	}
+
+	categories, err := a.store.GetCategoriesByProject(id)
+	if err != nil {
+		return 0, nil, err
+	}
+	result := []apiCategory{}
+	for _, c := range categories {
+		result = append(result, apiCategory{Id: c.Id, Name: c.Name})
+	}
+	return http.StatusOK, result, nil
+}
+
+// addCategory creates the category if needed and assigns it to the project
+func (a *api) addCategory(r *http.Request) (int, any, error) {
+	id, err := pathId(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	project, err := a.store.GetProjectById(id)
+	if err != nil {
+		return 0, nil, err
+	}
+	if project.Id == 0 {
+		return 0, nil, errNotFound
+	}
+
+	var in apiCategory
+	if err := decodeJSON(r, &in); err != nil {
+		return 0, nil, err
+	}
+	if strings.TrimSpace(in.Name) == "" {
+		return 0, nil, badRequest("name must not be empty")
+	}
+
+	category, err := a.store.SaveCategory(in.Name)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := a.store.AssignCategoriesToProject(project.Id, []int{category.Id}); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, apiCategory{Id: category.Id, Name: category.Name}, nil
+}
+
+// sync is the peer side of notes sync -url, it takes the changes of the
+// caller and answers with its own
+func (a *api) sync(r *http.Request) (int, any, error) {
+	var remote Changeset
+	// a first sync sends every note, more than the usual limit
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 64<<20)).Decode(&remote); err != nil {
+		return 0, nil, badRequest("invalid JSON: %v", err)
+	}
+	if remote.Replica == "" {
+		return 0, nil, badRequest("replica must not be empty")
+	}
+
+	local, _, err := a.store.(*Store).ServeSync(remote)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, local, nil
+}
@@ -0,0 +1,364 @@
+package tui
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testToken = "secret"
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	s := &Store{Path: filepath.Join(t.TempDir(), "notes.db")}
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.conn.Close() })
+	return s
+}
+
+// testProject returns a seeded project and its first category
+func testProject(t *testing.T, s *Store, name string) (Project, Category) {
+	t.Helper()
+	project, err := s.GetProjectByName(name)
+	if err != nil || project.Id == 0 {
+		t.Fatalf("project %q: %v", name, err)
+	}
+	categories, err := s.GetCategoriesByProject(project.Id)
+	if err != nil || len(categories) == 0 {
+		t.Fatalf("categories of %q: %v", name, err)
+	}
+	return project, categories[0]
+}
+
+type apiClient struct {
+	t     *testing.T
+	url   string
+	token string
+}
+
+func newTestAPI(t *testing.T, s *Store) apiClient {
+	t.Helper()
+	server := httptest.NewServer(NewAPIHandler(s, testToken))
+	t.Cleanup(server.Close)
+	return apiClient{t: t, url: server.URL, token: testToken}
+}
+
+// do sends body as JSON and decodes the answer into out, it returns the status
+func (c apiClient) do(method, path string, body, out any) int {
+	c.t.Helper()
+	var data []byte
+	if s, ok := body.(string); ok {
+		data = []byte(s)
+	} else if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+
+	req, err := http.NewRequest(method, c.url+path, bytes.NewReader(data))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			c.t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestAPIAuthentication(t *testing.T) {
+	s := newTestStore(t)
+	c := newTestAPI(t, s)
+
+	for _, tc := range []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"wrong token", "guess", http.StatusUnauthorized},
+		{"right token", testToken, http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c.token = tc.token
+			var out any
+			if got := c.do(http.MethodGet, "/api/notes", nil, &out); got != tc.want {
+				t.Errorf("status %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAPIWithoutConfiguredToken(t *testing.T) {
+	s := newTestStore(t)
+	server := httptest.NewServer(NewAPIHandler(s, ""))
+	defer server.Close()
+
+	c := apiClient{t: t, url: server.URL, token: ""}
+	if got := c.do(http.MethodGet, "/api/notes", nil, nil); got != http.StatusUnauthorized {
+		t.Errorf("status %d, want %d", got, http.StatusUnauthorized)
+	}
+}
+
+func TestAPINoteCRUD(t *testing.T) {
+	s := newTestStore(t)
+	c := newTestAPI(t, s)
+	project, category := testProject(t, s, "Work")
+
+	var created apiNote
+	in := apiNote{Title: "Standup", Body: "- [ ] review", TotalTime: "15m", ProjectId: project.Id, CategoryId: category.Id}
+	if got := c.do(http.MethodPost, "/api/notes", in, &created); got != http.StatusCreated {
+		t.Fatalf("create: status %d", got)
+	}
+	if created.Id == "" || created.Project != "Work" || created.Category != category.Name {
+		t.Fatalf("create: got %+v", created)
+	}
+
+	var fetched apiNote
+	if got := c.do(http.MethodGet, "/api/notes/"+created.Id, nil, &fetched); got != http.StatusOK {
+		t.Fatalf("get: status %d", got)
+	}
+	if fetched.Title != "Standup" || fetched.Body != "- [ ] review" {
+		t.Errorf("get: got %+v", fetched)
+	}
+
+	update := fetched
+	update.Title = "Daily standup"
+	var updated apiNote
+	if got := c.do(http.MethodPut, "/api/notes/"+created.Id, update, &updated); got != http.StatusOK {
+		t.Fatalf("update: status %d", got)
+	}
+	if updated.Title != "Daily standup" || !updated.UpdatedAt.After(fetched.UpdatedAt) {
+		t.Errorf("update: got %+v", updated)
+	}
+
+	if got := c.do(http.MethodDelete, "/api/notes/"+created.Id, nil, nil); got != http.StatusNoContent {
+		t.Fatalf("delete: status %d", got)
+	}
+	var out any
+	if got := c.do(http.MethodGet, "/api/notes/"+created.Id, nil, &out); got != http.StatusNotFound {
+		t.Errorf("get after delete: status %d, want %d", got, http.StatusNotFound)
+	}
+	if got := c.do(http.MethodDelete, "/api/notes/"+created.Id, nil, &out); got != http.StatusNotFound {
+		t.Errorf("delete again: status %d, want %d", got, http.StatusNotFound)
+	}
+}
+
+func TestAPIStaleUpdate(t *testing.T) {
+	s := newTestStore(t)
+	c := newTestAPI(t, s)
+	project, category := testProject(t, s, "Work")
+
+	var created apiNote
+	in := apiNote{Title: "Report", TotalTime: "1h", ProjectId: project.Id, CategoryId: category.Id}
+	if got := c.do(http.MethodPost, "/api/notes", in, &created); got != http.StatusCreated {
+		t.Fatalf("create: status %d", got)
+	}
+
+	first := created
+	first.Body = "first"
+	if got := c.do(http.MethodPut, "/api/notes/"+created.Id, first, &apiNote{}); got != http.StatusOK {
+		t.Fatalf("first update: status %d", got)
+	}
+
+	// still carries the updated_at of the create
+	stale := created
+	stale.Body = "second"
+	var conflict struct {
+		Error   string  `json:"error"`
+		Current apiNote `json:"current"`
+	}
+	if got := c.do(http.MethodPut, "/api/notes/"+created.Id, stale, &conflict); got != http.StatusConflict {
+		t.Fatalf("stale update: status %d, want %d", got, http.StatusConflict)
+	}
+	if conflict.Current.Body != "first" || conflict.Error == "" {
+		t.Errorf("stale update: got %+v", conflict)
+	}
+
+	// without updated_at it could not be told stale
+	blind := created
+	blind.Body = "third"
+	blind.UpdatedAt = time.Time{}
+	if got := c.do(http.MethodPut, "/api/notes/"+created.Id, blind, &apiNote{}); got != http.StatusBadRequest {
+		t.Errorf("update without updated_at: status %d, want %d", got, http.StatusBadRequest)
+	}
+
+	note, err := s.GetNoteById(created.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if note.Body != "first" {
+		t.Errorf("stale update was saved: body %q", note.Body)
+	}
+}
+
+func TestAPIListNotes(t *testing.T) {
+	s := newTestStore(t)
+	c := newTestAPI(t, s)
+	project, category := testProject(t, s, "Work")
+
+	for _, date := range []string{"2024-03-04", "2024-03-05", "2024-03-07"} {
+		in := apiNote{Title: "Note of " + date, TotalTime: "1h", ProjectId: project.Id, CategoryId: category.Id, Date: date}
+		if got := c.do(http.MethodPost, "/api/notes", in, &apiNote{}); got != http.StatusCreated {
+			t.Fatalf("create %s: status %d", date, got)
+		}
+	}
+
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{"?date=2024-03-05", []string{"Note of 2024-03-05"}},
+		{"?date=2024-03-06", nil},
+		{"?from=2024-03-04&to=2024-03-05", []string{"Note of 2024-03-04", "Note of 2024-03-05"}},
+		{"?from=2024-03-01&to=2024-03-31", []string{"Note of 2024-03-04", "Note of 2024-03-05", "Note of 2024-03-07"}},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			var notes []apiNote
+			if got := c.do(http.MethodGet, "/api/notes"+tc.query, nil, &notes); got != http.StatusOK {
+				t.Fatalf("status %d", got)
+			}
+			var titles []string
+			for _, note := range notes {
+				titles = append(titles, note.Title)
+			}
+			if len(titles) != len(tc.want) {
+				t.Fatalf("got %v, want %v", titles, tc.want)
+			}
+			for i := range titles {
+				if titles[i] != tc.want[i] {
+					t.Errorf("got %v, want %v", titles, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestAPIBadRequests(t *testing.T) {
+	s := newTestStore(t)
+	c := newTestAPI(t, s)
+	work, workCategory := testProject(t, s, "Work")
+	hobbies, hobbiesCategory := testProject(t, s, "Hobbies")
+	general, err := s.GetProjectByName("General")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		path   string
+		body   any
+	}{
+		{"empty title", http.MethodPost, "/api/notes",
+			apiNote{Title: " ", ProjectId: work.Id, CategoryId: workCategory.Id}},
+		{"unknown project", http.MethodPost, "/api/notes",
+			apiNote{Title: "x", ProjectId: 999, CategoryId: workCategory.Id}},
+		{"no category", http.MethodPost, "/api/notes",
+			apiNote{Title: "x", ProjectId: work.Id}},
+		{"category of another project", http.MethodPost, "/api/notes",
+			apiNote{Title: "x", ProjectId: hobbies.Id, CategoryId: workCategory.Id}},
+		{"project without categories", http.MethodPost, "/api/notes",
+			apiNote{Title: "x", ProjectId: general.Id, CategoryId: hobbiesCategory.Id}},
+		{"bad date", http.MethodPost, "/api/notes",
+			apiNote{Title: "x", ProjectId: work.Id, CategoryId: workCategory.Id, Date: "04.03.2024"}},
+		{"unknown field", http.MethodPost, "/api/notes", `{"title": "x", "colour": "red"}`},
+		{"invalid JSON", http.MethodPost, "/api/notes", `{"title": `},
+		{"bad date query", http.MethodGet, "/api/notes?date=yesterday", nil},
+		{"from without to", http.MethodGet, "/api/notes?from=2024-03-01", nil},
+		{"to before from", http.MethodGet, "/api/notes?from=2024-03-02&to=2024-03-01", nil},
+		{"project without name", http.MethodPost, "/api/projects", apiProject{Description: "x"}},
+		{"category without name", http.MethodPost, "/api/projects/1/categories", apiCategory{}},
+		{"bad project id", http.MethodGet, "/api/projects/one", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out struct {
+				Error string `json:"error"`
+			}
+			if got := c.do(tc.method, tc.path, tc.body, &out); got != http.StatusBadRequest {
+				t.Errorf("status %d, want %d", got, http.StatusBadRequest)
+			}
+			if out.Error == "" {
+				t.Error("no error message")
+			}
+		})
+	}
+
+	notes, err := s.GetNotes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 0 {
+		t.Errorf("%d invalid notes were saved", len(notes))
+	}
+}
+
+func TestAPIProjectsAndCategories(t *testing.T) {
+	s := newTestStore(t)
+	c := newTestAPI(t, s)
+
+	var projects []apiProject
+	if got := c.do(http.MethodGet, "/api/projects", nil, &projects); got != http.StatusOK {
+		t.Fatalf("list: status %d", got)
+	}
+	if len(projects) != len(mockProjects) {
+		t.Errorf("list: got %d projects, want %d", len(projects), len(mockProjects))
+	}
+
+	var created apiProject
+	if got := c.do(http.MethodPost, "/api/projects", apiProject{Name: "Acme", Description: "client"}, &created); got != http.StatusCreated {
+		t.Fatalf("create: status %d", got)
+	}
+	if got := c.do(http.MethodPost, "/api/projects", apiProject{Name: "Acme"}, &apiProject{}); got != http.StatusConflict {
+		t.Errorf("create twice: status %d, want %d", got, http.StatusConflict)
+	}
+
+	var fetched apiProject
+	if got := c.do(http.MethodGet, "/api/projects/"+strconv.Itoa(created.Id), nil, &fetched); got != http.StatusOK {
+		t.Fatalf("get: status %d", got)
+	}
+	if fetched != created {
+		t.Errorf("get: got %+v, want %+v", fetched, created)
+	}
+	var out any
+	if got := c.do(http.MethodGet, "/api/projects/999", nil, &out); got != http.StatusNotFound {
+		t.Errorf("get unknown: status %d, want %d", got, http.StatusNotFound)
+	}
+
+	var category apiCategory
+	path := "/api/projects/" + strconv.Itoa(created.Id) + "/categories"
+	if got := c.do(http.MethodPost, path, apiCategory{Name: "Meetings"}, &category); got != http.StatusCreated {
+		t.Fatalf("add category: status %d", got)
+	}
+	var categories []apiCategory
+	if got := c.do(http.MethodGet, path, nil, &categories); got != http.StatusOK {
+		t.Fatalf("list categories: status %d", got)
+	}
+	if len(categories) != 1 || categories[0] != category {
+		t.Errorf("list categories: got %+v", categories)
+	}
+
+	// the new project and category take notes
+	in := apiNote{Title: "Kickoff", TotalTime: "1h", ProjectId: created.Id, CategoryId: category.Id, Date: time.Now().Format(dateLayout)}
+	if got := c.do(http.MethodPost, "/api/notes", in, &apiNote{}); got != http.StatusCreated {
+		t.Errorf("note in new project: status %d", got)
+	}
+}
@@ -0,0 +1,472 @@
+package tui
+
+import (
+	"crypto/cipher"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/charmbracelet/bubbles/filepicker"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// attachmentsDirName is the folder next to the database holding the files,
+// each stored once under the sha256 of its content
+const attachmentsDirName = "attachments"
+
+// Attachment is a file kept with a note. Names are encrypted like titles,
+// the file contents are not.
+type Attachment struct {
+	Id      int
+	NoteId  string
+	Name    string
+	Hash    string
+	Size    int64
+	AddedAt time.Time
+}
+
+func (a Attachment) String() string {
+	return fmt.Sprintf("%s (%s)", a.Name, formatSize(a.Size))
+}
+
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
+
+func (s *Store) initAttachments() error {
+	createTableAttachmentsStmt := `
+        CREATE TABLE IF NOT EXISTS Attachments (
+            Id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+            NoteId TEXT NOT NULL,
+            Name TEXT NOT NULL,
+            Hash TEXT NOT NULL,
+            Size INTEGER NOT NULL,
+            AddedAt TIMESTAMP NOT NULL
+        );`
+
+	if _, err := s.conn.Exec(createTableAttachmentsStmt); err != nil {
+		return err
+	}
+
+	_, err := s.conn.Exec("CREATE INDEX IF NOT EXISTS AttachmentsNoteId ON Attachments (NoteId);")
+	return err
+}
+
+// AttachmentsDir is the content-addressed folder next to the database
+func (s *Store) AttachmentsDir() string {
+	return filepath.Join(filepath.Dir(s.Path), attachmentsDirName)
+}
+
+func (s *Store) blobPath(hash string) string {
+	return filepath.Join(s.AttachmentsDir(), hash[:2], hash)
+}
+
+// AddAttachment copies the file at path into the attachments folder and
+// attaches it to the note
+func (s *Store) AddAttachment(noteId, path string) (Attachment, error) {
+	note, err := s.GetNoteById(noteId)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if note.Id == "" {
+		return Attachment{}, fmt.Errorf("no note with id %q", noteId)
+	}
+
+	hash, size, err := s.storeBlob(path)
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	a := Attachment{NoteId: noteId, Name: filepath.Base(path), Hash: hash, Size: size, AddedAt: time.Now().UTC()}
+	name, err := s.seal(a.Name, noteId, "Attachment")
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	result, err := s.conn.Exec("INSERT INTO Attachments (NoteId, Name, Hash, Size, AddedAt) VALUES (?, ?, ?, ?, ?);",
+		a.NoteId, name, a.Hash, a.Size, a.AddedAt)
+	if err != nil {
+		return Attachment{}, err
+	}
+	id, err := result.LastInsertId()
+	a.Id = int(id)
+	return a, err
+}
+
+// storeBlob copies the file under its hash, a file stored before is kept
+func (s *Store) storeBlob(path string) (string, int64, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer src.Close()
+
+	if info, err := src.Stat(); err != nil {
+		return "", 0, err
+	} else if info.IsDir() {
+		return "", 0, fmt.Errorf("%s is a folder", path)
+	}
+
+	if err := os.MkdirAll(s.AttachmentsDir(), 0o755); err != nil {
+		return "", 0, err
+	}
+	tmp, err := os.CreateTemp(s.AttachmentsDir(), "incoming-*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), src)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	blob := s.blobPath(hash)
+	if _, err := os.Stat(blob); err == nil {
+		return hash, size, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(blob), 0o755); err != nil {
+		return "", 0, err
+	}
+	return hash, size, os.Rename(tmp.Name(), blob)
+}
+
+// resealAttachmentNames encrypts the attachment names with a new key, nil
+// stores them in the clear
+func (s *Store) resealAttachmentNames(tx *sql.Tx, aead cipher.AEAD) error {
+	type row struct {
+		id           int
+		noteId, name string
+	}
+	var attachments []row
+	rows, err := tx.Query("SELECT Id, NoteId, Name FROM Attachments;")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.noteId, &r.name); err != nil {
+			rows.Close()
+			return err
+		}
+		attachments = append(attachments, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, r := range attachments {
+		name, err := openValue(s.aead, r.name, r.noteId, "Attachment")
+		if err != nil {
+			return err
+		}
+		if name, err = sealValue(aead, name, r.noteId, "Attachment"); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE Attachments SET Name = ? WHERE Id = ?;", name, r.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Attachments returns the attachments of the note, oldest first
+func (s *Store) Attachments(noteId string) ([]Attachment, error) {
+	rows, err := s.conn.Query("SELECT Id, NoteId, Name, Hash, Size, AddedAt FROM Attachments WHERE NoteId = ? ORDER BY AddedAt, Id;", noteId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []Attachment
+	for rows.Next() {
+		var a Attachment
+		if err := rows.Scan(&a.Id, &a.NoteId, &a.Name, &a.Hash, &a.Size, &a.AddedAt); err != nil {
+			return nil, err
+		}
+		if a.Name, err = s.open(a.Name, a.NoteId, "Attachment"); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+// AttachmentById returns the attachment with the id, ok is false without one
+func (s *Store) AttachmentById(id int) (Attachment, bool, error) {
+	var a Attachment
+	err := s.conn.QueryRow("SELECT Id, NoteId, Name, Hash, Size, AddedAt FROM Attachments WHERE Id = ?;", id).
+		Scan(&a.Id, &a.NoteId, &a.Name, &a.Hash, &a.Size, &a.AddedAt)
+	if err == sql.ErrNoRows {
+		return Attachment{}, false, nil
+	} else if err != nil {
+		return Attachment{}, false, err
+	}
+	if a.Name, err = s.open(a.Name, a.NoteId, "Attachment"); err != nil {
+		return Attachment{}, false, err
+	}
+	return a, true, nil
+}
+
+// OpenAttachment copies the attachment under its own name into a temporary
+// folder, so the opener can tell its type, and opens it with the system opener
+func (s *Store) OpenAttachment(a Attachment) error {
+	dir := filepath.Join(os.TempDir(), "notes-attachments", a.Hash[:12])
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, filepath.Base(a.Name))
+	if err := copyFile(s.blobPath(a.Hash), path); err != nil {
+		return err
+	}
+	return openWithSystem(path)
+}
+
+func openWithSystem(path string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", path)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", path)
+	default:
+		cmd = exec.Command("xdg-open", path)
+	}
+	// the opener may keep running with the file, it is not waited for
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+func (s *Store) attachmentHashes(noteId string) ([]string, error) {
+	rows, err := s.conn.Query("SELECT Hash FROM Attachments WHERE NoteId = ?;", noteId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
+
+// removeBlobs deletes the stored files of hashes no attachment uses anymore
+func (s *Store) removeBlobs(hashes []string) error {
+	for _, hash := range hashes {
+		var used int
+		if err := s.conn.QueryRow("SELECT count(*) FROM Attachments WHERE Hash = ?;", hash).Scan(&used); err != nil {
+			return err
+		}
+		if used > 0 {
+			continue
+		}
+		if err := os.Remove(s.blobPath(hash)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// PruneAttachments removes the attachments of notes that are gone and the
+// stored files no attachment uses, e.g. after a crash while attaching. It
+// returns the number of files removed.
+func (s *Store) PruneAttachments() (int, error) {
+	if _, err := s.conn.Exec("DELETE FROM Attachments WHERE NoteId NOT IN (SELECT Id FROM Notes);"); err != nil {
+		return 0, err
+	}
+
+	used := map[string]bool{}
+	rows, err := s.conn.Query("SELECT DISTINCT Hash FROM Attachments;")
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		used[hash] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	err = filepath.WalkDir(s.AttachmentsDir(), func(path string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return filepath.SkipDir
+		} else if err != nil {
+			return err
+		}
+		if entry.IsDir() || used[entry.Name()] {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+// showAttachments opens the attachment list of the note in the detail view
+func (m *model) showAttachments(note Note) error {
+	store, err := m.sqlite()
+	if err != nil {
+		return err
+	}
+	attachments, err := store.Attachments(note.Id)
+	if err != nil {
+		return err
+	}
+	m.attachments = attachments
+	if m.attachmentCursor >= len(attachments) {
+		m.attachmentCursor = max(len(attachments)-1, 0)
+	}
+	m.state = attachmentsView
+	return nil
+}
+
+func newFilePicker(height int) filepicker.Model {
+	picker := filepicker.New()
+	picker.CurrentDirectory, _ = os.Getwd()
+	picker.AutoHeight = false
+	picker.Height = 15
+	if height > 15 {
+		picker.Height = height - 8
+	}
+	// esc leaves the picker, going up a folder keeps its other keys
+	picker.KeyMap.Back = key.NewBinding(key.WithKeys("h", "backspace", "left"), key.WithHelp("h", "back"))
+	return picker
+}
+
+func (m model) updateAttachments(msg tea.KeyMsg) (model, tea.Cmd) {
+	note, ok := m.selectedNote()
+	if !ok {
+		m.state = listView
+		return m, nil
+	}
+
+	if m.state == attachPickView {
+		if msg.String() == "esc" {
+			m.state = attachmentsView
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.filePicker, cmd = m.filePicker.Update(msg)
+		if selected, path := m.filePicker.DidSelectFile(msg); selected {
+			store, err := m.sqlite()
+			if err == nil {
+				_, err = store.AddAttachment(note.Id, path)
+			}
+			if err != nil {
+				m.statusMsg = "error: " + err.Error()
+			} else {
+				m.statusMsg = fmt.Sprintf("attached %s", filepath.Base(path))
+			}
+			if err := m.showAttachments(note); err != nil {
+				m.statusMsg = "error: " + err.Error()
+			}
+			m.attachmentCursor = len(m.attachments) - 1
+			return m, nil
+		}
+		return m, cmd
+	}
+
+	switch msg.String() {
+	case "esc": // Back to the note, which lists the attachments
+		if err := m.showNote(note); err != nil {
+			m.statusMsg = "error: " + err.Error()
+		}
+		m.state = noteDetailView
+	case "up", "k":
+		if m.attachmentCursor > 0 {
+			m.attachmentCursor--
+		}
+	case "down", "j":
+		if m.attachmentCursor < len(m.attachments)-1 {
+			m.attachmentCursor++
+		}
+	case "enter": // Open with the system opener
+		if m.attachmentCursor < len(m.attachments) {
+			store, err := m.sqlite()
+			if err == nil {
+				err = store.OpenAttachment(m.attachments[m.attachmentCursor])
+			}
+			if err != nil {
+				m.statusMsg = "error: " + err.Error()
+			}
+		}
+	case "a": // Attach a file
+		m.filePicker = newFilePicker(m.height)
+		m.state = attachPickView
+		return m, m.filePicker.Init()
+	}
+	return m, nil
+}
+
+func (m model) attachmentsView() string {
+	note, _ := m.selectedNote()
+	s := editTitleNoteStyle.Render("Attachments of "+note.Title) + "\n\n"
+
+	if m.state == attachPickView {
+		s += "Pick a file to attach\n\n" + m.filePicker.View() + "\n"
+		if m.statusMsg != "" {
+			s += statusStyle.Render(m.statusMsg) + "\n"
+		}
+		return s + faintStyle.Render("enter - attach, h - up a folder, esc - cancel")
+	}
+
+	if len(m.attachments) == 0 {
+		s += faintStyle.Render("no attachments yet") + "\n"
+	}
+	for i, a := range m.attachments {
+		cursor := "  "
+		if i == m.attachmentCursor {
+			cursor = "> "
+		}
+		s += cursor + a.String() + " " + faintStyle.Render(a.AddedAt.Local().Format("2006-01-02 15:04")) + "\n"
+	}
+	s += "\n"
+	if m.statusMsg != "" {
+		s += statusStyle.Render(m.statusMsg) + "\n\n"
+	}
+	return s + faintStyle.Render("enter - open, a - attach a file, esc - back")
+}
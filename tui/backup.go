@@ -0,0 +1,278 @@
+package tui
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const (
+	backupPrefix     = "notes-"
+	backupSuffix     = ".db"
+	backupTimeLayout = "20060102-150405"
+
+	// pages copied per backup step, the TUI may write between steps
+	backupStepPages = 128
+)
+
+// BackupPolicy says where backups go and which of them rotation keeps
+type BackupPolicy struct {
+	Dir       string
+	KeepLast  int // newest backups to keep
+	KeepDaily int // days for which the newest backup of the day is kept
+}
+
+// Backup copies the open database into a timestamped file in dir with the
+// SQLite online backup API, it is safe while the TUI is running
+func (s *Store) Backup(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, backupPrefix+time.Now().Format(backupTimeLayout)+backupSuffix)
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+
+	if err := s.backupTo(tmp); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := checkDatabase(tmp); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("backup is damaged: %w", err)
+	}
+	return path, os.Rename(tmp, path)
+}
+
+func (s *Store) backupTo(path string) error {
+	ctx := context.Background()
+
+	dstDB, err := sql.Open("sqlite3", sqliteDSN(path, ""))
+	if err != nil {
+		return err
+	}
+	defer dstDB.Close()
+
+	dst, err := dstDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	src, err := s.conn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	return dst.Raw(func(dstDriver any) error {
+		return src.Raw(func(srcDriver any) error {
+			dstConn, ok := dstDriver.(*sqlite3.SQLiteConn)
+			srcConn, ok2 := srcDriver.(*sqlite3.SQLiteConn)
+			if !ok || !ok2 {
+				return errors.New("backup needs the sqlite3 driver")
+			}
+
+			backup, err := dstConn.Backup("main", srcConn, "main")
+			if err != nil {
+				return err
+			}
+			for {
+				done, err := backup.Step(backupStepPages)
+				if err != nil {
+					backup.Close()
+					return err
+				}
+				if done {
+					break
+				}
+				time.Sleep(10 * time.Millisecond) // busy or more pages left
+			}
+			return backup.Finish()
+		})
+	})
+}
+
+// checkDatabase opens path read only and runs the SQLite integrity check
+func checkDatabase(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	db, err := sql.Open("sqlite3", sqliteDSN(path, "mode=ro"))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := db.Query("PRAGMA integrity_check;")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return err
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("integrity check failed: %s", strings.Join(problems, "; "))
+	}
+
+	var tables int
+	err = db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'Notes';").Scan(&tables)
+	if err != nil {
+		return err
+	}
+	if tables == 0 {
+		return errors.New("not a notes database")
+	}
+	return nil
+}
+
+// Backups returns the backup files of dir, newest first
+func Backups(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if _, ok := backupTime(entry.Name()); ok && !entry.IsDir() {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	// the timestamp layout sorts like the time
+	sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+	return paths, nil
+}
+
+func backupTime(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+		return time.Time{}, false
+	}
+	stamp := strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupSuffix)
+	t, err := time.ParseInLocation(backupTimeLayout, stamp, time.Local)
+	return t, err == nil
+}
+
+// RotateBackups removes the backups the policy does not keep and returns them
+func RotateBackups(policy BackupPolicy) ([]string, error) {
+	paths, err := Backups(policy.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := map[string]bool{}
+	for i, path := range paths {
+		if i < policy.KeepLast {
+			keep[path] = true
+		}
+	}
+
+	// paths are newest first, so the first one seen of a day is its newest
+	days := map[string]bool{}
+	for _, path := range paths {
+		t, _ := backupTime(filepath.Base(path))
+		day := t.Format(dateLayout)
+		if days[day] {
+			continue
+		}
+		if len(days) >= policy.KeepDaily {
+			break
+		}
+		days[day] = true
+		keep[path] = true
+	}
+
+	var removed []string
+	for _, path := range paths {
+		if keep[path] {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// Restore swaps the backup in as the database at dbPath after checking its
+// integrity. The replaced database is kept next to it with a .before-restore
+// suffix. Nothing may have the database open while restoring.
+func Restore(backupPath, dbPath string) error {
+	if err := checkDatabase(backupPath); err != nil {
+		return fmt.Errorf("%s: %w", backupPath, err)
+	}
+
+	tmp := dbPath + ".restore"
+	if err := copyFile(backupPath, tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// the write-ahead log holds the latest commits, keep it with the old database
+	for _, suffix := range []string{"", "-wal"} {
+		if _, err := os.Stat(dbPath + suffix); err != nil {
+			continue
+		}
+		if err := copyFile(dbPath+suffix, dbPath+".before-restore"+suffix); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+	}
+
+	// journal files belong to the replaced database
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			os.Remove(tmp)
+			return err
+		}
+	}
+	return os.Rename(tmp, dbPath)
+}
+
+func copyFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Sync(); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
@@ -0,0 +1,217 @@
+package tui
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const (
+	BudgetPerWeek  = "week"
+	BudgetPerMonth = "month"
+	BudgetTotal    = "total"
+)
+
+// Budget is the planned time of a project, per week, per month or in total,
+// optionally limited to a date range
+type Budget struct {
+	Project Project
+	Hours   float64
+	Period  string
+	Start   time.Time // zero when open
+	End     time.Time // zero when open
+}
+
+// BudgetStatus is a budget with the time logged in its current period
+type BudgetStatus struct {
+	Budget
+	From, To time.Time // the period the logged time is counted in
+	Logged   time.Duration
+	Active   bool // false when the date is outside Start and End
+}
+
+func (b BudgetStatus) Planned() time.Duration {
+	return time.Duration(b.Hours * float64(time.Hour))
+}
+
+func (b BudgetStatus) Over() bool {
+	return b.Active && b.Logged > b.Planned()
+}
+
+func (b BudgetStatus) Percent() float64 {
+	if b.Planned() == 0 {
+		return 0
+	}
+	return float64(b.Logged) / float64(b.Planned())
+}
+
+func (b BudgetStatus) String() string {
+	period := "per " + b.Period
+	if b.Period == BudgetTotal {
+		period = "in total"
+	}
+	return fmt.Sprintf("%s / %s %s", formatDuration(b.Logged), formatDuration(b.Planned()), period)
+}
+
+// PeriodLabel describes the days the logged time is counted in
+func (b BudgetStatus) PeriodLabel() string {
+	from, to := "start", "open end"
+	if b.From.Year() > 1 {
+		from = b.From.Format("02 Jan 2006")
+	}
+	if b.To.Year() < 9999 {
+		to = b.To.Format("02 Jan 2006")
+	}
+	return from + " - " + to
+}
+
+// period returns the days counted for the budget on date
+func (b Budget) period(date time.Time) (time.Time, time.Time, bool) {
+	date = date.UTC().Truncate(24 * time.Hour)
+
+	var from, to time.Time
+	switch b.Period {
+	case BudgetPerWeek:
+		from = weekStart(date)
+		to = from.AddDate(0, 0, 6)
+	case BudgetPerMonth:
+		from = time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+		to = from.AddDate(0, 1, -1)
+	default:
+		from = time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)
+		to = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+	}
+
+	if !b.Start.IsZero() && b.Start.After(from) {
+		from = b.Start
+	}
+	if !b.End.IsZero() && b.End.Before(to) {
+		to = b.End
+	}
+
+	active := (b.Start.IsZero() || !date.Before(b.Start)) && (b.End.IsZero() || !date.After(b.End))
+	return from, to, active
+}
+
+func (s *Store) initBudgets() error {
+	createTableProjectBudgetsStmt := `
+        CREATE TABLE IF NOT EXISTS ProjectBudgets (
+            ProjectId INTEGER NOT NULL PRIMARY KEY,
+            Hours REAL NOT NULL,
+            Period TEXT NOT NULL,
+            StartDate TEXT,
+            EndDate TEXT,
+            FOREIGN KEY (ProjectId) REFERENCES Projects(Id)
+        );`
+
+	_, err := s.conn.Exec(createTableProjectBudgetsStmt)
+	return err
+}
+
+func (s *Store) SaveBudget(b Budget) error {
+	switch b.Period {
+	case BudgetPerWeek, BudgetPerMonth, BudgetTotal:
+	default:
+		return fmt.Errorf("invalid budget period %q, use week, month or total", b.Period)
+	}
+	if b.Hours <= 0 {
+		return fmt.Errorf("budget hours must be positive")
+	}
+
+	upsertQuery := `
+    INSERT INTO ProjectBudgets (ProjectId, Hours, Period, StartDate, EndDate)
+    VALUES (?, ?, ?, ?, ?)
+    ON CONFLICT(ProjectId) DO UPDATE
+    SET
+        Hours=excluded.Hours,
+        Period=excluded.Period,
+        StartDate=excluded.StartDate,
+        EndDate=excluded.EndDate;`
+
+	_, err := s.conn.Exec(upsertQuery, b.Project.Id, b.Hours, b.Period, nullableDate(b.Start), nullableDate(b.End))
+	return err
+}
+
+func (s *Store) DeleteBudget(projectId int) error {
+	_, err := s.conn.Exec("DELETE FROM ProjectBudgets WHERE ProjectId = ?", projectId)
+	return err
+}
+
+func (s *Store) GetBudgets() ([]Budget, error) {
+	query := `
+        SELECT p.Id, p.Name, p.Description, b.Hours, b.Period, b.StartDate, b.EndDate
+        FROM ProjectBudgets b
+        INNER JOIN Projects p ON b.ProjectId = p.Id
+        ORDER BY p.Name;
+    `
+
+	rows, err := s.conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var budgets []Budget
+	for rows.Next() {
+		var b Budget
+		var start, end sql.NullString
+		if err := rows.Scan(&b.Project.Id, &b.Project.Name, &b.Project.Description, &b.Hours, &b.Period, &start, &end); err != nil {
+			return nil, err
+		}
+		b.Start, _ = time.Parse(dateLayout, start.String)
+		b.End, _ = time.Parse(dateLayout, end.String)
+		budgets = append(budgets, b)
+	}
+	return budgets, rows.Err()
+}
+
+// GetLoggedTime sums the TotalTime of the project notes between two days
+func (s *Store) GetLoggedTime(projectId int, from, to time.Time) (time.Duration, error) {
+	query := `
+        SELECT TotalTime FROM Notes
+        WHERE ProjectId = ? AND Draft = 0 AND date(CreatedAt) BETWEEN date(?) AND date(?);
+    `
+
+	rows, err := s.conn.Query(query, projectId, from.Format(dateLayout), to.Format(dateLayout))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var total time.Duration
+	for rows.Next() {
+		var totalTime sql.NullString
+		if err := rows.Scan(&totalTime); err != nil {
+			return 0, err
+		}
+		total += parseTotalTime(totalTime.String)
+	}
+	return total, rows.Err()
+}
+
+// GetBudgetStatuses returns every budget with the time logged in the period containing date
+func (s *Store) GetBudgetStatuses(date time.Time) ([]BudgetStatus, error) {
+	budgets, err := s.GetBudgets()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]BudgetStatus, 0, len(budgets))
+	for _, b := range budgets {
+		from, to, active := b.period(date)
+		logged, err := s.GetLoggedTime(b.Project.Id, from, to)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, BudgetStatus{Budget: b, From: from, To: to, Logged: logged, Active: active})
+	}
+	return statuses, nil
+}
+
+// nullableDate stores a zero date as NULL
+func nullableDate(date time.Time) any {
+	if date.IsZero() {
+		return nil
+	}
+	return date.UTC().Format(dateLayout)
+}
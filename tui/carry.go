@@ -0,0 +1,155 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// carryOverTitle is the title of the notes open items are carried over into
+const carryOverTitle = "Carried over"
+
+// carriedPattern matches what carrying adds to an item, so an item carried
+// again keeps the link to the note it was first written in
+var carriedPattern = regexp.MustCompile(`\s*\(from \[\[([^\[\]\n]+)\]\], carried (\d+) days?\)$`)
+
+// workingDaysBetween counts the working days after from up to and with to
+func workingDaysBetween(from, to time.Time) int {
+	days := 0
+	for day := to; day.After(from); day = previousWorkingDay(day) {
+		days++
+	}
+	return days
+}
+
+// carriedItem is the line of the open item carried over from its note on
+// from to the day to
+func carriedItem(task Task, from, to time.Time) string {
+	text, origin, days := task.Text, task.Note.Id, 0
+	if loc := carriedPattern.FindStringSubmatchIndex(text); loc != nil {
+		origin = text[loc[2]:loc[3]]
+		days, _ = strconv.Atoi(text[loc[4]:loc[5]])
+		text = text[:loc[0]]
+	}
+	days += workingDaysBetween(from, to)
+
+	unit := "days"
+	if days == 1 {
+		unit = "day"
+	}
+	return fmt.Sprintf("- [ ] %s (from [[%s]], carried %d %s)", text, origin, days, unit)
+}
+
+// markCarried marks the items on lines as carried over with "- [>]", which is
+// neither open nor done, so they are not listed twice
+func markCarried(body string, lines []int) string {
+	split := strings.Split(body, "\n")
+	for _, line := range lines {
+		if match := taskPattern.FindStringSubmatchIndex(split[line]); match != nil {
+			split[line] = split[line][:match[4]] + ">" + split[line][match[5]:]
+		}
+	}
+	return strings.Join(split, "\n")
+}
+
+// openTaskCount counts the unchecked items of the notes of date
+func openTaskCount(storage Storage, date time.Time) (int, error) {
+	notes, err := storage.GetNotesByDate(date)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, note := range notes {
+		for _, task := range parseTasks(note.Body) {
+			if !task.Done {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+// CarryOver moves the unchecked items of the notes of from into a "Carried
+// over" note per project on to. Each item links back to the note it was
+// written in and tells for how many working days it was carried.
+func CarryOver(storage Storage, from, to time.Time) (int, error) {
+	notes, err := storage.GetNotesByDate(from)
+	if err != nil {
+		return 0, err
+	}
+
+	type carry struct {
+		note  Note // first note of the project, its category is used
+		items []string
+	}
+	var projects []*carry
+	byProject := map[int]*carry{}
+	var sources []Note
+	var sourceLines [][]int
+
+	for _, note := range notes {
+		var lines []int
+		for _, task := range noteTasks(note) {
+			if task.Done {
+				continue
+			}
+			c, ok := byProject[note.Project.Id]
+			if !ok {
+				c = &carry{note: note}
+				byProject[note.Project.Id] = c
+				projects = append(projects, c)
+			}
+			c.items = append(c.items, carriedItem(task, from, to))
+			lines = append(lines, task.Line)
+		}
+		if len(lines) > 0 {
+			sources = append(sources, note)
+			sourceLines = append(sourceLines, lines)
+		}
+	}
+
+	// the new notes are saved first, a failure after that leaves items
+	// listed twice rather than lost
+	carried := 0
+	for _, c := range projects {
+		note := Note{Title: carryOverTitle, Body: strings.Join(c.items, "\n"), TotalTime: formatDuration(0)}
+		if err := storage.SaveNoteWithProject(note, c.note.Project.Id, c.note.Category.Id, to); err != nil {
+			return 0, err
+		}
+		carried += len(c.items)
+	}
+	for i, note := range sources {
+		note.Body = markCarried(note.Body, sourceLines[i])
+		if err := storage.SaveNoteWithProject(note, note.Project.Id, note.Category.Id, note.CreatedAt); err != nil {
+			return carried, err
+		}
+	}
+	return carried, nil
+}
+
+// checkCarryOver offers to carry the open items of the previous working day
+// over when today is a working day without a "Carried over" note yet
+func (m *model) checkCarryOver() {
+	m.carryCount = 0
+
+	day := m.currentDate
+	if !day.Equal(today()) || day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		return
+	}
+	for _, note := range m.notes {
+		if note.Title == carryOverTitle && !note.isPlaceholder() {
+			return
+		}
+	}
+
+	from := previousWorkingDay(day)
+	count, err := openTaskCount(m.store, from)
+	if err != nil {
+		m.statusMsg = "error: " + err.Error()
+		return
+	}
+	m.carryFrom, m.carryCount = from, count
+}
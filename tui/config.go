@@ -0,0 +1,141 @@
+package tui
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+)
+
+const DefaultConfigPath = "./config.json"
+
+// Config holds the user settings read from config.json, every field is optional
+type Config struct {
+	// StandupTemplate is a text/template file used for the standup report
+	StandupTemplate string `json:"standup_template"`
+
+	// DailyTarget is the time to log per working day, default "8h"
+	DailyTarget string `json:"daily_target"`
+	// WeekdayTargets overrides DailyTarget per weekday, e.g. {"friday": "6h"}.
+	// Saturday and sunday have no target unless they are set here.
+	WeekdayTargets map[string]string `json:"weekday_targets"`
+
+	// Currency is printed after invoice totals, e.g. "EUR"
+	Currency string `json:"currency"`
+	// InvoiceRounding rounds the time of each invoiced note, e.g. "15m"
+	InvoiceRounding string `json:"invoice_rounding"`
+	// InvoiceRoundingMode is up (default), down or nearest
+	InvoiceRoundingMode string `json:"invoice_rounding_mode"`
+	// InvoiceDir is where invoice files are written, default "./invoices"
+	InvoiceDir string `json:"invoice_dir"`
+
+	// BackupDir is where backups are written, default "./backups"
+	BackupDir string `json:"backup_dir"`
+	// BackupKeepLast is the number of newest backups rotation keeps, default 10
+	BackupKeepLast *int `json:"backup_keep_last"`
+	// BackupKeepDaily keeps the newest backup of that many days, default 14
+	BackupKeepDaily *int `json:"backup_keep_daily"`
+	// AutoBackup makes a backup every time the TUI starts
+	AutoBackup bool `json:"auto_backup"`
+
+	// APIToken must be sent as "Authorization: Bearer <token>" to notes serve
+	APIToken string `json:"api_token"`
+	// DashboardPassword protects the web dashboard of notes serve, empty leaves it open
+	DashboardPassword string `json:"dashboard_password"`
+
+	// GitAuthor picks the commits notes from-git reads, e.g. "me@example.com",
+	// default the user.email of each repository
+	GitAuthor string `json:"git_author"`
+
+	// StatusFormat is the template of notes status, e.g. "{today_total} / {target}"
+	StatusFormat string `json:"status_format"`
+
+	// PomodoroWork, PomodoroBreak and PomodoroLongBreak are the interval
+	// lengths, default "25m", "5m" and "15m"
+	PomodoroWork      string `json:"pomodoro_work"`
+	PomodoroBreak     string `json:"pomodoro_break"`
+	PomodoroLongBreak string `json:"pomodoro_long_break"`
+	// PomodoroLongEvery makes every that many breaks a long one, default 4
+	PomodoroLongEvery int `json:"pomodoro_long_every"`
+}
+
+const defaultDailyTarget = "8h"
+
+const (
+	defaultBackupDir       = "./backups"
+	defaultBackupKeepLast  = 10
+	defaultBackupKeepDaily = 14
+)
+
+// LoadConfig reads the config file, a missing file gives the defaults
+func LoadConfig(path string) (Config, error) {
+	var config Config
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	} else if err != nil {
+		return config, err
+	}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// TargetFor returns the time to log on date, zero means no target
+func (c Config) TargetFor(date time.Time) time.Duration {
+	weekday := strings.ToLower(date.Weekday().String())
+	for day, target := range c.WeekdayTargets {
+		if strings.ToLower(day) == weekday {
+			return parseTotalTime(target)
+		}
+	}
+
+	if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+		return 0
+	}
+	if c.DailyTarget == "" {
+		return parseTotalTime(defaultDailyTarget)
+	}
+	return parseTotalTime(c.DailyTarget)
+}
+
+// PomodoroSettings returns the pomodoro intervals with the defaults filled in
+func (c Config) PomodoroSettings() PomodoroSettings {
+	interval := func(value, fallback string) time.Duration {
+		if d := parseTotalTime(value); d > 0 {
+			return d
+		}
+		return parseTotalTime(fallback)
+	}
+
+	settings := PomodoroSettings{
+		Work:      interval(c.PomodoroWork, defaultPomodoroWork),
+		Break:     interval(c.PomodoroBreak, defaultPomodoroBreak),
+		LongBreak: interval(c.PomodoroLongBreak, defaultPomodoroLongBreak),
+		LongEvery: c.PomodoroLongEvery,
+	}
+	if settings.LongEvery <= 0 {
+		settings.LongEvery = defaultPomodoroLongEvery
+	}
+	return settings
+}
+
+// BackupPolicy returns the backup settings with the defaults filled in
+func (c Config) BackupPolicy() BackupPolicy {
+	policy := BackupPolicy{Dir: c.BackupDir, KeepLast: defaultBackupKeepLast, KeepDaily: defaultBackupKeepDaily}
+	if policy.Dir == "" {
+		policy.Dir = defaultBackupDir
+	}
+	if c.BackupKeepLast != nil {
+		policy.KeepLast = *c.BackupKeepLast
+	}
+	if c.BackupKeepDaily != nil {
+		policy.KeepDaily = *c.BackupKeepDaily
+	}
+	return policy
+}
@@ -0,0 +1,103 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// conflictMsg reports a save that lost against a change made elsewhere
+type conflictMsg struct {
+	conflict *ConflictError
+}
+
+// saveNote saves the note with the chosen project and category and goes back
+// to the list, a conflict opens the conflict screen instead
+func (m model) saveNote(note Note) tea.Cmd {
+	projectId, categoryId, currentDate := m.currProject.Id, m.currCategory.Id, m.currentDate
+	return func() tea.Msg {
+		err := m.store.SaveNoteWithProject(note, projectId, categoryId, currentDate)
+		var conflict *ConflictError
+		if errors.As(err, &conflict) {
+			return conflictMsg{conflict}
+		} else if err != nil {
+			return errMsg{err}
+		}
+		notes, err := loadNotes(m.store, currentDate)
+		if err != nil {
+			return errMsg{err}
+		}
+		return saveCompleteMsg{notes: notes}
+	}
+}
+
+// mergeBodies puts both bodies into one text with conflict markers for editing by hand
+func mergeBodies(mine, theirs string) string {
+	if mine == theirs {
+		return mine
+	}
+	return "<<<<<<< mine\n" + strings.TrimSuffix(mine, "\n") + "\n=======\n" +
+		strings.TrimSuffix(theirs, "\n") + "\n>>>>>>> theirs\n"
+}
+
+func (m model) updateConflict(key string) (model, tea.Cmd) {
+	mine, theirs := m.conflict.Mine, m.conflict.Theirs
+
+	switch m.state {
+	case conflictView:
+		switch key {
+		case "m": // Keep mine, overwriting their change
+			mine.UpdatedAt = theirs.UpdatedAt
+			m.isLoading = true
+			return m, tea.Batch(m.spinner.Tick, m.saveNote(mine))
+		case "t": // Take theirs, dropping my change
+			m.isLoading = true
+			currentDate := m.currentDate
+			return m, tea.Batch(m.spinner.Tick, func() tea.Msg {
+				notes, err := loadNotes(m.store, currentDate)
+				if err != nil {
+					return errMsg{err}
+				}
+				return saveCompleteMsg{notes: notes}
+			})
+		case "e": // Merge the two bodies by hand
+			m.textArea.SetValue(mergeBodies(mine.Body, theirs.Body))
+			m.textArea.Focus()
+			m.state = mergeView
+		}
+
+	case mergeView:
+		switch key {
+		case "esc":
+			m.textArea.Blur()
+			m.state = conflictView
+		case "ctrl+s":
+			mine.Body = m.textArea.Value()
+			mine.UpdatedAt = theirs.UpdatedAt
+			m.textArea.Blur()
+			m.isLoading = true
+			return m, tea.Batch(m.spinner.Tick, m.saveNote(mine))
+		}
+	}
+	return m, nil
+}
+
+func (m model) conflictView() string {
+	mine, theirs := m.conflict.Mine, m.conflict.Theirs
+
+	version := func(label string, note Note) string {
+		s := editTitleNoteStyle.Render(label) + "\n\n" +
+			note.Title + " " + faintStyle.Render(note.TotalTime) + "\n"
+		if !note.UpdatedAt.IsZero() {
+			s += faintStyle.Render("updated "+note.UpdatedAt.Local().Format("2006-01-02 15:04:05")) + "\n"
+		}
+		return s + "\n" + note.Body + "\n\n"
+	}
+
+	return warningStyle.Render(fmt.Sprintf("%q was changed somewhere else while you edited it.", theirs.Title)) + "\n\n" +
+		version("Mine", Note{Title: mine.Title, Body: mine.Body, TotalTime: mine.TotalTime}) +
+		version("Theirs", theirs) +
+		faintStyle.Render("m - keep mine, t - take theirs, e - merge by hand")
+}
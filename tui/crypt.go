@@ -0,0 +1,325 @@
+package tui
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Encrypted titles and bodies are stored as encryptedPrefix followed by the
+// base64 of nonce and AES-GCM ciphertext. The note id and the column name are
+// the additional data, so values cannot be swapped between rows or columns.
+const encryptedPrefix = "enc:v1:"
+
+// scrypt parameters recommended for interactive logins
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	keyLength    = 32
+	saltLength   = 16
+	verifierText = "notes-bubbletea-cli"
+)
+
+var (
+	ErrLocked          = errors.New("notes are encrypted, unlock them with the passphrase first")
+	ErrWrongPassphrase = errors.New("wrong passphrase")
+)
+
+func (s *Store) initEncryption() error {
+	// Salt and Verifier are set while notes are encrypted, Verifier is a
+	// known text sealed with the key to check the passphrase
+	createTableEncryptionStmt := `
+        CREATE TABLE IF NOT EXISTS Encryption (
+            Id INTEGER NOT NULL PRIMARY KEY CHECK (Id = 1),
+            Salt BLOB NOT NULL,
+            Verifier TEXT NOT NULL
+        );`
+
+	if _, err := s.conn.Exec(createTableEncryptionStmt); err != nil {
+		return err
+	}
+
+	var count int
+	if err := s.conn.QueryRow("SELECT count(*) FROM Encryption;").Scan(&count); err != nil {
+		return err
+	}
+	s.encrypted = count > 0
+	return nil
+}
+
+// Encrypted tells whether note titles and bodies are encrypted
+func (s *Store) Encrypted() bool {
+	return s.encrypted
+}
+
+// Locked tells whether notes are encrypted and no passphrase was given yet
+func (s *Store) Locked() bool {
+	return s.encrypted && s.aead == nil
+}
+
+// Unlock derives the key from the passphrase, notes can be read and saved afterwards
+func (s *Store) Unlock(passphrase string) error {
+	if !s.encrypted {
+		return nil
+	}
+
+	var salt []byte
+	var verifier string
+	if err := s.conn.QueryRow("SELECT Salt, Verifier FROM Encryption WHERE Id = 1;").Scan(&salt, &verifier); err != nil {
+		return err
+	}
+
+	aead, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	if text, err := openValue(aead, verifier, "", "verifier"); err != nil || text != verifierText {
+		return ErrWrongPassphrase
+	}
+
+	s.aead = aead
+	// the links and checklists of the notes could not be read while they
+	// were locked
+	if err := s.initLinks(); err != nil {
+		return err
+	}
+	return s.initTasks()
+}
+
+// ChangePassphrase re-encrypts the title and body of every note, and their
+// copies in attachments, templates and recurring notes, with a key from the
+// new passphrase. An empty passphrase turns encryption off. Notes must be
+// unlocked first.
+func (s *Store) ChangePassphrase(passphrase string) error {
+	if s.Locked() {
+		return ErrLocked
+	}
+
+	var aead cipher.AEAD
+	var salt []byte
+	if passphrase != "" {
+		salt = make([]byte, saltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		var err error
+		if aead, err = deriveKey(passphrase, salt); err != nil {
+			return err
+		}
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	type row struct{ id, title, body string }
+	var notes []row
+	rows, err := tx.Query("SELECT Id, Title, Body FROM Notes;")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.title, &r.body); err != nil {
+			rows.Close()
+			return err
+		}
+		notes = append(notes, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, r := range notes {
+		title, err := openValue(s.aead, r.title, r.id, "Title")
+		if err != nil {
+			return err
+		}
+		body, err := openValue(s.aead, r.body, r.id, "Body")
+		if err != nil {
+			return err
+		}
+		if title, err = sealValue(aead, title, r.id, "Title"); err != nil {
+			return err
+		}
+		if body, err = sealValue(aead, body, r.id, "Body"); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE Notes SET Title = ?, Body = ? WHERE Id = ?", title, body, r.id); err != nil {
+			return err
+		}
+	}
+
+	if err := s.resealAttachmentNames(tx, aead); err != nil {
+		return err
+	}
+	if err := s.resealRows(tx, aead, "Templates", "template", "Name", "Body"); err != nil {
+		return err
+	}
+	if err := s.resealRows(tx, aead, "Recurrences", "recurrence", "Title", "Body"); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM Encryption;"); err != nil {
+		return err
+	}
+	if aead != nil {
+		verifier, err := sealValue(aead, verifierText, "", "verifier")
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO Encryption (Id, Salt, Verifier) VALUES (1, ?, ?);", salt, verifier); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.aead = aead
+	s.encrypted = aead != nil
+	return nil
+}
+
+func deriveKey(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keyLength)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts a column value of a note when encryption is on
+func (s *Store) seal(value, noteId, column string) (string, error) {
+	if s.Locked() {
+		return "", ErrLocked
+	}
+	return sealValue(s.aead, value, noteId, column)
+}
+
+// open decrypts a column value of a note, plain values are returned as they are
+func (s *Store) open(value, noteId, column string) (string, error) {
+	return openValue(s.aead, value, noteId, column)
+}
+
+func sealValue(aead cipher.AEAD, value, noteId, column string) (string, error) {
+	if aead == nil {
+		return value, nil
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(noteId+"|"+column))
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func openValue(aead cipher.AEAD, value, noteId, column string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil // written before encryption was turned on
+	}
+	if aead == nil {
+		return "", ErrLocked
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("damaged encrypted value of note " + noteId)
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, []byte(noteId+"|"+column))
+	if err != nil {
+		return "", errors.New("unable to decrypt note " + noteId)
+	}
+	return string(plain), nil
+}
+
+// rowId is the additional data of tables other than Notes, which copy note
+// titles and bodies, e.g. rowId("template", 3)
+func rowId(kind string, id int) string {
+	return fmt.Sprintf("%s:%d", kind, id)
+}
+
+// resealRows re-encrypts the columns of every row of a table other than
+// Notes, whose values are sealed with rowId(kind, Id)
+func (s *Store) resealRows(tx *sql.Tx, aead cipher.AEAD, table, kind string, columns ...string) error {
+	type row struct {
+		id     int
+		values []string
+	}
+	var resealed []row
+	rows, err := tx.Query("SELECT Id, " + strings.Join(columns, ", ") + " FROM " + table + ";")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		r := row{values: make([]string, len(columns))}
+		dest := []any{&r.id}
+		for i := range r.values {
+			dest = append(dest, &r.values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return err
+		}
+		resealed = append(resealed, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	set := make([]string, len(columns))
+	for i, column := range columns {
+		set[i] = column + " = ?"
+	}
+	update := "UPDATE " + table + " SET " + strings.Join(set, ", ") + " WHERE Id = ?;"
+
+	for _, r := range resealed {
+		var args []any
+		for i, value := range r.values {
+			plain, err := openValue(s.aead, value, rowId(kind, r.id), columns[i])
+			if err != nil {
+				return err
+			}
+			if value, err = sealValue(aead, plain, rowId(kind, r.id), columns[i]); err != nil {
+				return err
+			}
+			args = append(args, value)
+		}
+		if _, err := tx.Exec(update, append(args, r.id)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decryptNotes opens the title and body of notes read from the database
+func (s *Store) decryptNotes(notes []Note) ([]Note, error) {
+	var err error
+	for i := range notes {
+		if notes[i].Title, err = s.open(notes[i].Title, notes[i].Id, "Title"); err != nil {
+			return nil, err
+		}
+		if notes[i].Body, err = s.open(notes[i].Body, notes[i].Id, "Body"); err != nil {
+			return nil, err
+		}
+	}
+	return notes, nil
+}
@@ -0,0 +1,265 @@
+package tui
+
+import (
+	"crypto/subtle"
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed web
+var webFiles embed.FS
+
+const (
+	dashboardDay   = "day"
+	dashboardWeek  = "week"
+	dashboardMonth = "month"
+)
+
+// dashboardBar is one row of an SVG bar chart
+type dashboardBar struct {
+	Label    string
+	Duration time.Duration
+	Percent  float64 // of the longest bar
+}
+
+// dashboardDayNotes groups the notes of one day for the list
+type dashboardDayNotes struct {
+	Date  time.Time
+	Notes []Note
+	Total time.Duration
+}
+
+type dashboardPage struct {
+	View       string // day, week, month or search
+	Date       time.Time
+	From, To   time.Time
+	Prev, Next time.Time
+	Query      string
+
+	Days       []dashboardDayNotes
+	Total      time.Duration
+	Drafts     int
+	ByProject  []dashboardBar
+	ByCategory []dashboardBar
+	ByDay      []dashboardBar
+}
+
+// dashboard serves the read-only web UI
+type dashboard struct {
+	store    Storage
+	password string
+	tmpl     *template.Template
+}
+
+// NewDashboardHandler returns the read-only web dashboard. With a password
+// the browser asks for it with basic auth, the user name is not checked.
+func NewDashboardHandler(store Storage, password string) http.Handler {
+	tmpl := template.Must(template.New("").Funcs(template.FuncMap{
+		"duration": formatDuration,
+		"date":     func(t time.Time) string { return t.Format(dateLayout) },
+		"day":      func(t time.Time) string { return t.Format("Mon, 02 Jan 2006") },
+		"month":    func(t time.Time) string { return t.Format("January 2006") },
+		"barWidth": func(percent float64) float64 { return percent * 400 },
+		"barY":     func(i int) int { return i * 28 },
+		"chartHeight": func(bars []dashboardBar) int {
+			return len(bars) * 28
+		},
+	}).ParseFS(webFiles, "web/*.html"))
+
+	d := &dashboard{store: store, password: password, tmpl: tmpl}
+
+	static, _ := fs.Sub(webFiles, "web")
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServer(http.FS(static))))
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/"+dashboardWeek, http.StatusFound)
+	})
+	mux.HandleFunc("GET /{view}", d.period)
+	mux.HandleFunc("GET /search", d.search)
+
+	return d.authenticate(mux)
+}
+
+func (d *dashboard) authenticate(next http.Handler) http.Handler {
+	if d.password == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, password, ok := r.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(password), []byte(d.password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="notes"`)
+			http.Error(w, "password required", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (d *dashboard) render(w http.ResponseWriter, page dashboardPage) {
+	var b strings.Builder
+	if err := d.tmpl.ExecuteTemplate(&b, "dashboard.html", page); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+// periodRange returns the days a view shows around date and the date of the
+// previous and next period
+func periodRange(view string, date time.Time) (from, to, prev, next time.Time) {
+	switch view {
+	case dashboardWeek:
+		from = weekStart(date)
+		to = from.AddDate(0, 0, 6)
+		return from, to, from.AddDate(0, 0, -7), from.AddDate(0, 0, 7)
+	case dashboardMonth:
+		from = time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+		to = from.AddDate(0, 1, -1)
+		return from, to, from.AddDate(0, -1, 0), from.AddDate(0, 1, 0)
+	default:
+		return date, date, date.AddDate(0, 0, -1), date.AddDate(0, 0, 1)
+	}
+}
+
+func (d *dashboard) period(w http.ResponseWriter, r *http.Request) {
+	view := r.PathValue("view")
+	if view != dashboardDay && view != dashboardWeek && view != dashboardMonth {
+		http.NotFound(w, r)
+		return
+	}
+
+	date := today()
+	if value := r.URL.Query().Get("date"); value != "" {
+		parsed, err := time.Parse(dateLayout, value)
+		if err != nil {
+			http.Error(w, "date must look like "+dateLayout, http.StatusBadRequest)
+			return
+		}
+		date = parsed
+	}
+
+	from, to, prev, next := periodRange(view, date)
+	notes, err := d.store.GetNotesByDateRange(from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	page := dashboardPage{View: view, Date: date, From: from, To: to, Prev: prev, Next: next}
+	page.summarize(notes)
+	if view != dashboardDay {
+		page.ByDay = barsByDay(notes, from, to)
+	}
+	d.render(w, page)
+}
+
+// search looks through titles, bodies, projects and categories of every
+// note. It runs in Go because titles and bodies may be encrypted.
+func (d *dashboard) search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	page := dashboardPage{View: "search", Date: today(), Query: query}
+
+	if query != "" {
+		notes, err := d.store.GetNotes()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var found []Note
+		for _, note := range notes {
+			if containsFold([]string{note.Title, note.Body, note.Project.Name, note.Category.Name}, query) {
+				found = append(found, note)
+			}
+		}
+		page.summarize(found)
+	}
+	d.render(w, page)
+}
+
+// summarize fills the day list and the project and category totals
+func (p *dashboardPage) summarize(notes []Note) {
+	sortNotes(notes, sortByCreated)
+
+	byProject := map[string]time.Duration{}
+	byCategory := map[string]time.Duration{}
+	for _, note := range notes {
+		day := note.CreatedAt.UTC().Truncate(24 * time.Hour)
+		if len(p.Days) == 0 || !p.Days[len(p.Days)-1].Date.Equal(day) {
+			p.Days = append(p.Days, dashboardDayNotes{Date: day})
+		}
+		current := &p.Days[len(p.Days)-1]
+		current.Notes = append(current.Notes, note)
+
+		if note.Draft {
+			p.Drafts++
+			continue
+		}
+		duration := parseTotalTime(note.TotalTime)
+		current.Total += duration
+		p.Total += duration
+		byProject[note.Project.Name] += duration
+
+		category := note.Category.Name
+		if category == "" {
+			category = "Uncategorised"
+		}
+		byCategory[category] += duration
+	}
+
+	p.ByProject = bars(byProject)
+	p.ByCategory = bars(byCategory)
+}
+
+// bars sorts the totals, longest first
+func bars(totals map[string]time.Duration) []dashboardBar {
+	var result []dashboardBar
+	for label, duration := range totals {
+		result = append(result, dashboardBar{Label: label, Duration: duration})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Duration != result[j].Duration {
+			return result[i].Duration > result[j].Duration
+		}
+		return result[i].Label < result[j].Label
+	})
+	return scaleBars(result)
+}
+
+// barsByDay has a bar for every day from from to to, also the empty ones
+func barsByDay(notes []Note, from, to time.Time) []dashboardBar {
+	totals := map[string]time.Duration{}
+	for _, note := range notes {
+		if !note.Draft {
+			totals[note.CreatedAt.UTC().Format(dateLayout)] += parseTotalTime(note.TotalTime)
+		}
+	}
+
+	var result []dashboardBar
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		result = append(result, dashboardBar{Label: day.Format("Mon 02"), Duration: totals[day.Format(dateLayout)]})
+	}
+	return scaleBars(result)
+}
+
+func scaleBars(result []dashboardBar) []dashboardBar {
+	var longest time.Duration
+	for _, bar := range result {
+		if bar.Duration > longest {
+			longest = bar.Duration
+		}
+	}
+	for i := range result {
+		if longest > 0 {
+			result[i].Percent = float64(result[i].Duration) / float64(longest)
+		}
+	}
+	return result
+}
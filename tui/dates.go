@@ -0,0 +1,33 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// today returns the current day the same way the list tracks currentDate
+func today() time.Time {
+	return time.Now().Truncate(24 * time.Hour)
+}
+
+// parseDateInput reads a date typed by the user, either YYYY-MM-DD or
+// one of today, yesterday and tomorrow
+func parseDateInput(s string) (time.Time, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "today":
+		return today(), nil
+	case "yesterday":
+		return today().AddDate(0, 0, -1), nil
+	case "tomorrow":
+		return today().AddDate(0, 0, 1), nil
+	}
+
+	date, err := time.Parse(dateLayout, strings.TrimSpace(s))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, use YYYY-MM-DD", s)
+	}
+	return date, nil
+}
@@ -0,0 +1,53 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseTotalTime reads the free-form TotalTime of a note.
+// Accepts Go durations ("1h30m"), clock style ("1:30") and plain hours ("1.5").
+// Anything else counts as zero.
+func parseTotalTime(s string) time.Duration {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if s == "" {
+		return 0
+	}
+
+	if d, err := time.ParseDuration(s); err == nil {
+		return d
+	}
+
+	if hours, minutes, ok := strings.Cut(s, ":"); ok {
+		h, err1 := strconv.Atoi(hours)
+		m, err2 := strconv.Atoi(minutes)
+		if err1 == nil && err2 == nil {
+			return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute
+		}
+		return 0
+	}
+
+	if h, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(h * float64(time.Hour))
+	}
+
+	return 0
+}
+
+// formatDuration prints a duration the way notes are usually logged, e.g. "1h30m"
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	h := int(d / time.Hour)
+	m := int((d % time.Hour) / time.Minute)
+
+	switch {
+	case h > 0 && m > 0:
+		return fmt.Sprintf("%dh%02dm", h, m)
+	case h > 0:
+		return fmt.Sprintf("%dh", h)
+	default:
+		return fmt.Sprintf("%dm", m)
+	}
+}
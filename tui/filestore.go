@@ -0,0 +1,619 @@
+package tui
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const DefaultNotesDir = "./worklog"
+
+// FileStore keeps the work log in a folder that can live in git:
+//
+//	projects.json              projects and their categories
+//	notes/2024-01-08/<id>.md   one note, YAML front matter and the body
+type FileStore struct {
+	Dir string // DefaultNotesDir when empty
+}
+
+type projectsFile struct {
+	Projects   []fileProject `json:"projects"`
+	Categories []Category    `json:"categories"`
+}
+
+type fileProject struct {
+	Id          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CategoryIds []int     `json:"category_ids"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (p fileProject) project() Project {
+	return Project{Id: p.Id, Name: p.Name, Description: p.Description, CreatedAt: p.CreatedAt, UpdatedAt: p.UpdatedAt}
+}
+
+func (f *FileStore) Init() error {
+	if f.Dir == "" {
+		f.Dir = DefaultNotesDir
+	}
+
+	if err := os.MkdirAll(filepath.Join(f.Dir, "notes"), 0o755); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(f.projectsPath()); err == nil {
+		return nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	// Insert mock projects and categories on first start
+	for _, project := range mockProjects {
+		if err := f.SaveProject(project); err != nil {
+			return err
+		}
+	}
+	for projectName, categoryNames := range mockAssignments {
+		project, err := f.GetProjectByName(projectName)
+		if err != nil {
+			return err
+		}
+		var ids []int
+		for _, name := range categoryNames {
+			category, err := f.SaveCategory(name)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, category.Id)
+		}
+		if err := f.AssignCategoriesToProject(project.Id, ids); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *FileStore) projectsPath() string {
+	return filepath.Join(f.Dir, "projects.json")
+}
+
+func (f *FileStore) dayDir(date time.Time) string {
+	return filepath.Join(f.Dir, "notes", date.UTC().Format(dateLayout))
+}
+
+func (f *FileStore) notePath(note Note) string {
+	return filepath.Join(f.dayDir(note.CreatedAt), note.Id+".md")
+}
+
+func (f *FileStore) loadProjects() (projectsFile, error) {
+	var pf projectsFile
+	data, err := os.ReadFile(f.projectsPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return pf, nil
+	} else if err != nil {
+		return pf, err
+	}
+	err = json.Unmarshal(data, &pf)
+	return pf, err
+}
+
+func (f *FileStore) saveProjects(pf projectsFile) error {
+	data, err := json.MarshalIndent(pf, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.projectsPath(), append(data, '\n'))
+}
+
+// writeFileAtomic never leaves a half written file behind
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (f *FileStore) SaveProject(project Project) error {
+	pf, err := f.loadProjects()
+	if err != nil {
+		return err
+	}
+
+	nextId := 1
+	for _, p := range pf.Projects {
+		if p.Name == project.Name {
+			return nil // same as ON CONFLICT(Name) DO NOTHING
+		}
+		if p.Id >= nextId {
+			nextId = p.Id + 1
+		}
+	}
+
+	now := time.Now().UTC()
+	pf.Projects = append(pf.Projects, fileProject{
+		Id:          nextId,
+		Name:        project.Name,
+		Description: project.Description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	})
+	return f.saveProjects(pf)
+}
+
+func (f *FileStore) GetProjects() ([]Project, error) {
+	pf, err := f.loadProjects()
+	if err != nil {
+		return nil, err
+	}
+
+	projects := []Project{}
+	for _, p := range pf.Projects {
+		projects = append(projects, p.project())
+	}
+	return projects, nil
+}
+
+func (f *FileStore) GetProjectById(projectId int) (Project, error) {
+	pf, err := f.loadProjects()
+	if err != nil {
+		return Project{}, err
+	}
+	for _, p := range pf.Projects {
+		if p.Id == projectId {
+			return p.project(), nil
+		}
+	}
+	return Project{}, nil // Return zero value
+}
+
+func (f *FileStore) GetProjectByName(name string) (Project, error) {
+	pf, err := f.loadProjects()
+	if err != nil {
+		return Project{}, err
+	}
+	for _, p := range pf.Projects {
+		if p.Name == name {
+			return p.project(), nil
+		}
+	}
+	return Project{}, nil // Return zero value
+}
+
+func (f *FileStore) SaveCategory(name string) (Category, error) {
+	pf, err := f.loadProjects()
+	if err != nil {
+		return Category{}, err
+	}
+
+	nextId := 1
+	for _, c := range pf.Categories {
+		if c.Name == name {
+			return c, nil
+		}
+		if c.Id >= nextId {
+			nextId = c.Id + 1
+		}
+	}
+
+	category := Category{Id: nextId, Name: name}
+	pf.Categories = append(pf.Categories, category)
+	return category, f.saveProjects(pf)
+}
+
+func (f *FileStore) GetCategoriesByProject(projectId int) ([]Category, error) {
+	pf, err := f.loadProjects()
+	if err != nil {
+		return nil, err
+	}
+
+	var categories []Category
+	for _, p := range pf.Projects {
+		if p.Id != projectId {
+			continue
+		}
+		for _, id := range p.CategoryIds {
+			for _, c := range pf.Categories {
+				if c.Id == id {
+					categories = append(categories, c)
+				}
+			}
+		}
+	}
+	return categories, nil
+}
+
+func (f *FileStore) AssignCategoriesToProject(projectId int, categoryIds []int) error {
+	pf, err := f.loadProjects()
+	if err != nil {
+		return err
+	}
+
+	for i, p := range pf.Projects {
+		if p.Id != projectId {
+			continue
+		}
+		for _, id := range categoryIds {
+			found := false
+			for _, existing := range p.CategoryIds {
+				found = found || existing == id
+			}
+			if !found {
+				pf.Projects[i].CategoryIds = append(pf.Projects[i].CategoryIds, id)
+			}
+		}
+	}
+	return f.saveProjects(pf)
+}
+
+// writeNote stores the note in the folder of its creation day
+func (f *FileStore) writeNote(note Note, pf projectsFile) error {
+	var b strings.Builder
+	field := func(name string, value any) {
+		encoded, _ := json.Marshal(value) // JSON strings are valid YAML
+		b.WriteString(name + ": " + string(encoded) + "\n")
+	}
+
+	b.WriteString("---\n")
+	field("id", note.Id)
+	field("title", note.Title)
+	field("project", note.Project.Name)
+	field("category", note.Category.Name)
+	field("time", note.TotalTime)
+	field("created", note.CreatedAt.UTC().Format(time.RFC3339Nano))
+	field("updated", note.UpdatedAt.UTC().Format(time.RFC3339Nano))
+	field("billable", note.Billable)
+	field("draft", note.Draft)
+	if note.RecurrenceId != 0 {
+		field("recurrence", note.RecurrenceId)
+	}
+	b.WriteString("---\n")
+	b.WriteString(note.Body)
+	if !strings.HasSuffix(note.Body, "\n") {
+		b.WriteString("\n")
+	}
+
+	if err := os.MkdirAll(f.dayDir(note.CreatedAt), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(f.notePath(note), []byte(b.String()))
+}
+
+// readNote parses a note file, project and category are resolved by name
+func (f *FileStore) readNote(path string, pf projectsFile) (Note, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Note{}, err
+	}
+	defer file.Close()
+
+	var note Note
+	var body strings.Builder
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	state := 0 // 0 before front matter, 1 inside, 2 body
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch state {
+		case 0:
+			if line != "---" {
+				return Note{}, fmt.Errorf("%s: missing front matter", path)
+			}
+			state = 1
+		case 1:
+			if line == "---" {
+				state = 2
+				continue
+			}
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			if err := setNoteField(&note, strings.TrimSpace(key), strings.TrimSpace(value), pf); err != nil {
+				return Note{}, fmt.Errorf("%s: %s: %w", path, key, err)
+			}
+		default:
+			body.WriteString(line + "\n")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Note{}, err
+	}
+
+	note.Body = strings.TrimSuffix(body.String(), "\n")
+	if note.Id == "" {
+		note.Id = strings.TrimSuffix(filepath.Base(path), ".md")
+	}
+	return note, nil
+}
+
+func setNoteField(note *Note, key, value string, pf projectsFile) error {
+	// values are JSON encoded, but accept plain YAML scalars edited by hand
+	text := value
+	if strings.HasPrefix(value, `"`) {
+		if err := json.Unmarshal([]byte(value), &text); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	switch key {
+	case "id":
+		note.Id = text
+	case "title":
+		note.Title = text
+	case "time":
+		note.TotalTime = text
+	case "project":
+		for _, p := range pf.Projects {
+			if p.Name == text {
+				note.Project = p.project()
+			}
+		}
+		if note.Project.Id == 0 {
+			note.Project.Name = text
+		}
+	case "category":
+		for _, c := range pf.Categories {
+			if c.Name == text {
+				note.Category = c
+			}
+		}
+		if note.Category.Id == 0 {
+			note.Category.Name = text
+		}
+	case "created":
+		note.CreatedAt, err = time.Parse(time.RFC3339Nano, text)
+	case "updated":
+		note.UpdatedAt, err = time.Parse(time.RFC3339Nano, text)
+	case "billable":
+		note.Billable, err = strconv.ParseBool(text)
+	case "draft":
+		note.Draft, err = strconv.ParseBool(text)
+	case "recurrence":
+		note.RecurrenceId, err = strconv.Atoi(text)
+	}
+	return err
+}
+
+// readDays reads the notes of every day folder accepted by keep
+func (f *FileStore) readDays(keep func(day string) bool) ([]Note, error) {
+	pf, err := f.loadProjects()
+	if err != nil {
+		return nil, err
+	}
+
+	days, err := os.ReadDir(filepath.Join(f.Dir, "notes"))
+	if err != nil {
+		return nil, err
+	}
+
+	var notes []Note
+	for _, day := range days {
+		if !day.IsDir() || !keep(day.Name()) {
+			continue
+		}
+		paths, err := filepath.Glob(filepath.Join(f.Dir, "notes", day.Name(), "*.md"))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			note, err := f.readNote(path, pf)
+			if err != nil {
+				return nil, err
+			}
+			notes = append(notes, note)
+		}
+	}
+
+	sort.SliceStable(notes, func(i, j int) bool { return notes[i].CreatedAt.Before(notes[j].CreatedAt) })
+	return notes, nil
+}
+
+func (f *FileStore) GetNotes() ([]Note, error) {
+	return f.readDays(func(string) bool { return true })
+}
+
+func (f *FileStore) GetNotesByDate(currentDate time.Time) ([]Note, error) {
+	day := currentDate.UTC().Format(dateLayout)
+	return f.readDays(func(d string) bool { return d == day })
+}
+
+func (f *FileStore) GetNotesByDateRange(from, to time.Time) ([]Note, error) {
+	first, last := from.UTC().Format(dateLayout), to.UTC().Format(dateLayout)
+	return f.readDays(func(d string) bool { return d >= first && d <= last })
+}
+
+func (f *FileStore) GetNotesByProject(projectId int) ([]Note, error) {
+	notes, err := f.GetNotes()
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := []Note{}
+	for _, note := range notes {
+		if note.Project.Id == projectId {
+			filtered = append(filtered, note)
+		}
+	}
+	return filtered, nil
+}
+
+// findNote returns the path of the note file, empty if there is none
+func (f *FileStore) findNote(noteId string) (string, error) {
+	if noteId == "" || strings.ContainsAny(noteId, `/\`) {
+		return "", nil
+	}
+	paths, err := filepath.Glob(filepath.Join(f.Dir, "notes", "*", noteId+".md"))
+	if err != nil || len(paths) == 0 {
+		return "", err
+	}
+	return paths[0], nil
+}
+
+func (f *FileStore) GetNoteById(noteId string) (Note, error) {
+	path, err := f.findNote(noteId)
+	if err != nil || path == "" {
+		return Note{}, err
+	}
+
+	pf, err := f.loadProjects()
+	if err != nil {
+		return Note{}, err
+	}
+	return f.readNote(path, pf)
+}
+
+func (f *FileStore) SaveNoteWithProject(note Note, projectId, category int, currentdate time.Time) error {
+	now := time.Now().UTC()
+
+	existing, err := f.GetNoteById(note.Id)
+	if err != nil {
+		return err
+	}
+	if existing.Id != "" && !note.UpdatedAt.IsZero() && !existing.UpdatedAt.Equal(note.UpdatedAt) {
+		return &ConflictError{Mine: note, Theirs: existing}
+	}
+
+	switch {
+	case note.Id == "":
+		note.Id = uuid.New().String()
+		note.CreatedAt = onDate(currentdate, now)
+		note.UpdatedAt = note.CreatedAt
+		note.Billable = true
+	case existing.Id != "":
+		// like the SQL upsert, creation date and flags of the row are kept
+		note.CreatedAt = existing.CreatedAt
+		note.RecurrenceId = existing.RecurrenceId
+		note.Billable = existing.Billable
+		note.UpdatedAt = now
+	default:
+		if note.CreatedAt.IsZero() {
+			note.CreatedAt = onDate(currentdate, now)
+		}
+		note.UpdatedAt = now
+		note.Billable = true
+	}
+
+	pf, err := f.loadProjects()
+	if err != nil {
+		return err
+	}
+
+	note.Project = Project{}
+	note.Category = Category{}
+	for _, p := range pf.Projects {
+		if p.Id == projectId {
+			note.Project = p.project()
+		}
+	}
+	for _, c := range pf.Categories {
+		if c.Id == category {
+			note.Category = c
+		}
+	}
+	if note.Project.Id == 0 {
+		return fmt.Errorf("unknown project id %d", projectId)
+	}
+
+	return f.writeNote(note, pf)
+}
+
+// updateNote rewrites a note file after change, the file moves when the day changes
+func (f *FileStore) updateNote(noteId string, change func(*Note)) error {
+	path, err := f.findNote(noteId)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return nil // same as an UPDATE matching no row
+	}
+
+	pf, err := f.loadProjects()
+	if err != nil {
+		return err
+	}
+	note, err := f.readNote(path, pf)
+	if err != nil {
+		return err
+	}
+
+	change(&note)
+	if err := f.writeNote(note, pf); err != nil {
+		return err
+	}
+	if f.notePath(note) != path {
+		return os.Remove(path)
+	}
+	return nil
+}
+
+func (f *FileStore) DeleteNote(noteId string) error {
+	path, err := f.findNote(noteId)
+	if err != nil || path == "" {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (f *FileStore) MoveNote(note Note, date time.Time) error {
+	return f.updateNote(note.Id, func(n *Note) {
+		n.CreatedAt = onDate(date, n.CreatedAt)
+		n.UpdatedAt = time.Now().UTC()
+	})
+}
+
+func (f *FileStore) CopyNote(note Note, date time.Time) error {
+	note.Id = ""
+	note.RecurrenceId = 0
+	return f.SaveNoteWithProject(note, note.Project.Id, note.Category.Id, date)
+}
+
+func (f *FileStore) CopyNotesByDate(from, to time.Time) (int, error) {
+	notes, err := f.GetNotesByDate(from)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, note := range notes {
+		if err := f.CopyNote(note, to); err != nil {
+			return 0, err
+		}
+	}
+	return len(notes), nil
+}
+
+func (f *FileStore) UpdateNoteCategory(noteId string, categoryId int) error {
+	pf, err := f.loadProjects()
+	if err != nil {
+		return err
+	}
+	return f.updateNote(noteId, func(n *Note) {
+		for _, c := range pf.Categories {
+			if c.Id == categoryId {
+				n.Category = c
+			}
+		}
+	})
+}
+
+func (f *FileStore) SetNoteDraft(noteId string, draft bool) error {
+	return f.updateNote(noteId, func(n *Note) { n.Draft = draft })
+}
+
+func (f *FileStore) SetNoteBillable(noteId string, billable bool) error {
+	return f.updateNote(noteId, func(n *Note) { n.Billable = billable })
+}
@@ -0,0 +1,154 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// gitNamespace makes the ids of git drafts stable, the same repo and day
+// always give the same note
+var gitNamespace = uuid.MustParse("6a3e1f0c-58d4-4a8e-9a55-0c1f1b1d2e7f")
+
+// gitTimeStep is what a day of commits is rounded up to, a single commit
+// still counts as one step
+const gitTimeStep = 15 * time.Minute
+
+type GitCommit struct {
+	Hash    string
+	Subject string
+	Time    time.Time
+}
+
+// GitCommits returns the commits of author on day in the repository, oldest
+// first. Merges are left out, commits of every branch are included.
+func GitCommits(repo, author string, day time.Time) ([]GitCommit, error) {
+	from := day.UTC().Truncate(24 * time.Hour)
+	to := from.Add(24 * time.Hour)
+
+	if _, err := git(repo, "rev-parse", "--git-dir"); err != nil {
+		return nil, err
+	}
+
+	if author == "" {
+		out, _ := git(repo, "config", "user.email")
+		if author = strings.TrimSpace(out); author == "" {
+			return nil, fmt.Errorf("%s has no user.email, set git_author in the config", repo)
+		}
+	}
+
+	// --since looks at the commit date, which is never before the author date
+	out, err := git(repo, "log", "--all", "--no-merges", "--author="+author,
+		"--since="+from.Format(time.RFC3339), "--format=%H%x1f%aI%x1f%s")
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []GitCommit
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 3 {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("commit %s: %w", fields[0], err)
+		}
+		if at.Before(from) || !at.Before(to) {
+			continue
+		}
+		commits = append(commits, GitCommit{Hash: fields[0], Subject: fields[2], Time: at.UTC()})
+	}
+	sort.Slice(commits, func(i, j int) bool { return commits[i].Time.Before(commits[j].Time) })
+	return commits, nil
+}
+
+func git(repo string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s in %s: %s", args[0], repo, msg)
+		}
+		return "", fmt.Errorf("git %s in %s: %w", args[0], repo, err)
+	}
+	return string(out), nil
+}
+
+// GitDrafts turns the commits of author in each repository on day into one
+// draft note per repository. The project comes from the git mappings, matched
+// against the repository path. Repositories without commits, or imported for
+// that day before, give no draft.
+func (s *Store) GitDrafts(repos []string, author string, day time.Time) ([]Note, error) {
+	var drafts []Note
+	seen := map[string]bool{}
+	for _, repo := range repos {
+		abs, err := filepath.Abs(repo)
+		if err != nil {
+			return nil, err
+		}
+		if seen[abs] {
+			continue
+		}
+		seen[abs] = true
+
+		commits, err := GitCommits(abs, author, day)
+		if err != nil {
+			return nil, err
+		}
+		if len(commits) == 0 {
+			continue
+		}
+
+		id := uuid.NewSHA1(gitNamespace, []byte(abs+"|"+day.UTC().Format(dateLayout))).String()
+		existing, err := s.GetNoteById(id)
+		if err != nil {
+			return nil, err
+		}
+		if existing.Id != "" {
+			continue
+		}
+
+		project, err := s.guessProject(mappingGit, abs)
+		if err != nil {
+			return nil, err
+		}
+		category, err := s.defaultCategory(project.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		var body strings.Builder
+		for _, commit := range commits {
+			fmt.Fprintf(&body, "- %s (%s)\n", commit.Subject, commit.Hash[:7])
+		}
+
+		// the time from the first to the last commit is only a guess, the
+		// review is there to correct it
+		spent := commits[len(commits)-1].Time.Sub(commits[0].Time)
+		spent = (spent + gitTimeStep - 1) / gitTimeStep * gitTimeStep
+		if spent < gitTimeStep {
+			spent = gitTimeStep
+		}
+
+		drafts = append(drafts, Note{
+			Id:        id,
+			Title:     filepath.Base(abs),
+			Body:      body.String(),
+			TotalTime: formatDuration(spent),
+			CreatedAt: commits[0].Time,
+			Project:   project,
+			Category:  category,
+			Draft:     true,
+		})
+	}
+	return drafts, nil
+}
@@ -0,0 +1,217 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	reviewTitle = iota
+	reviewTime
+	reviewBody
+)
+
+// reviewModel walks through draft notes, each one is accepted as is, edited
+// and accepted, or skipped. Accepted drafts are saved right away.
+type reviewModel struct {
+	store   *Store
+	drafts  []Note
+	index   int
+	editing bool
+	focus   int
+
+	title textinput.Model
+	time  textinput.Model
+	body  textarea.Model
+
+	saved   int
+	skipped int
+	err     error
+	done    bool
+}
+
+func (m reviewModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m reviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	if key.String() == "ctrl+c" {
+		m.done = true
+		return m, tea.Quit
+	}
+
+	if m.editing {
+		return m.updateEdit(key)
+	}
+
+	switch key.String() {
+	case "q", "esc":
+		m.done = true
+		return m, tea.Quit
+	case "up", "k":
+		if m.index > 0 {
+			m.index--
+		}
+	case "down", "j":
+		if m.index < len(m.drafts)-1 {
+			m.index++
+		}
+	case "enter", "a": // Accept the draft as it is
+		return m.accept(m.drafts[m.index])
+	case "e": // Edit the draft before accepting it
+		draft := m.drafts[m.index]
+		m.title.SetValue(draft.Title)
+		m.time.SetValue(draft.TotalTime)
+		m.body.SetValue(draft.Body)
+		m.editing = true
+		m.setFocus(reviewTitle)
+	case "x": // Skip the draft, from-git offers it again next time
+		m.skipped++
+		return m.next()
+	}
+	return m, nil
+}
+
+func (m reviewModel) updateEdit(key tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch key.String() {
+	case "esc":
+		m.editing = false
+		m.setFocus(-1)
+		return m, nil
+	case "tab":
+		m.setFocus((m.focus + 1) % 3)
+		return m, nil
+	case "shift+tab":
+		m.setFocus((m.focus + 2) % 3)
+		return m, nil
+	case "ctrl+s":
+		if m.title.Value() == "" {
+			m.err = fmt.Errorf("title must not be empty")
+			return m, nil
+		}
+		draft := m.drafts[m.index]
+		draft.Title = m.title.Value()
+		draft.TotalTime = m.time.Value()
+		draft.Body = m.body.Value()
+		m.editing = false
+		m.setFocus(-1)
+		return m.accept(draft)
+	}
+
+	var cmd tea.Cmd
+	switch m.focus {
+	case reviewTitle:
+		m.title, cmd = m.title.Update(key)
+	case reviewTime:
+		m.time, cmd = m.time.Update(key)
+	case reviewBody:
+		m.body, cmd = m.body.Update(key)
+	}
+	return m, cmd
+}
+
+func (m *reviewModel) setFocus(focus int) {
+	m.focus = focus
+	m.title.Blur()
+	m.time.Blur()
+	m.body.Blur()
+	switch focus {
+	case reviewTitle:
+		m.title.Focus()
+	case reviewTime:
+		m.time.Focus()
+	case reviewBody:
+		m.body.Focus()
+	}
+}
+
+func (m reviewModel) accept(draft Note) (tea.Model, tea.Cmd) {
+	draft.Draft = false
+	if err := m.store.SaveNoteWithProject(draft, draft.Project.Id, draft.Category.Id, draft.CreatedAt); err != nil {
+		m.err = err
+		return m, nil
+	}
+	m.saved++
+	return m.next()
+}
+
+// next drops the current draft, quitting after the last one
+func (m reviewModel) next() (tea.Model, tea.Cmd) {
+	m.err = nil
+	m.drafts = append(m.drafts[:m.index:m.index], m.drafts[m.index+1:]...)
+	if m.index >= len(m.drafts) {
+		m.index = len(m.drafts) - 1
+	}
+	if len(m.drafts) == 0 {
+		m.done = true
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m reviewModel) View() string {
+	if m.done {
+		return ""
+	}
+
+	s := editTitleNoteStyle.Render(fmt.Sprintf("Draft %d of %d", m.index+1, len(m.drafts))) + "\n\n"
+	for i, draft := range m.drafts {
+		cursor := "  "
+		if i == m.index {
+			cursor = "> "
+		}
+		s += fmt.Sprintf("%s%s %s %s\n", cursor, draft.Title, faintStyle.Render(draft.TotalTime), faintStyle.Render(draft.Project.Name))
+	}
+	s += "\n"
+
+	if m.editing {
+		s += "Title\n" + m.title.View() + "\n\nTime\n" + m.time.View() + "\n\n" + m.body.View() + "\n\n"
+	} else {
+		draft := m.drafts[m.index]
+		s += fmt.Sprintf("%s - %s / %s\n\n%s\n", draft.CreatedAt.Local().Format("2006-01-02 15:04"),
+			draft.Project.Name, draft.Category.Name, draft.Body)
+	}
+
+	if m.err != nil {
+		s += warningStyle.Render("error: "+m.err.Error()) + "\n\n"
+	}
+
+	if m.editing {
+		return s + faintStyle.Render("tab - next field, ctrl+s - accept, esc - back") + "\n"
+	}
+	return s + faintStyle.Render("a/enter - accept, e - edit, x - skip, q - quit") + "\n"
+}
+
+// ReviewDrafts shows the drafts one by one for accepting, editing or
+// skipping, and returns how many were saved and skipped
+func ReviewDrafts(store *Store, drafts []Note) (saved, skipped int, err error) {
+	if len(drafts) == 0 {
+		return 0, 0, nil
+	}
+
+	m := reviewModel{
+		store:  store,
+		drafts: drafts,
+		title:  textinput.New(),
+		time:   textinput.New(),
+		body:   textarea.New(),
+	}
+	m.time.Placeholder = "1h30m"
+	m.body.SetWidth(80)
+	m.body.SetHeight(10)
+	m.setFocus(-1)
+
+	result, err := tea.NewProgram(m).Run()
+	if err != nil {
+		return 0, 0, err
+	}
+	m = result.(reviewModel)
+	return m.saved, m.skipped, nil
+}
@@ -0,0 +1,319 @@
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const icsTimeLayout = "20060102T150405Z"
+
+// icsNamespace makes note ids of imported events stable, importing a file
+// twice does not duplicate its notes
+var icsNamespace = uuid.MustParse("6f1e4d6c-2f0b-4f4e-9a53-3c0c1f3a8a10")
+
+// ICSEvent is a VEVENT read from an .ics file
+type ICSEvent struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Start       time.Time
+	End         time.Time
+}
+
+func (e ICSEvent) Duration() time.Duration {
+	if e.End.After(e.Start) {
+		return e.End.Sub(e.Start)
+	}
+	return 0
+}
+
+// ExportICS writes every note as a VEVENT lasting its logged time
+func ExportICS(w io.Writer, notes []Note) error {
+	b := bufio.NewWriter(w)
+	line := func(s string) { writeICSLine(b, s) }
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//notes-bubbletea-cli//notes//EN")
+	line("CALSCALE:GREGORIAN")
+
+	stamp := time.Now().UTC().Format(icsTimeLayout)
+	for _, note := range notes {
+		duration := parseTotalTime(note.TotalTime)
+		if duration == 0 {
+			duration = 15 * time.Minute // keep the event visible
+		}
+
+		line("BEGIN:VEVENT")
+		line("UID:" + note.Id + "@notes")
+		line("DTSTAMP:" + stamp)
+		line("DTSTART:" + note.CreatedAt.UTC().Format(icsTimeLayout))
+		line("DURATION:" + icsDuration(duration))
+		line("SUMMARY:" + escapeICS(note.Title))
+		if note.Body != "" {
+			line("DESCRIPTION:" + escapeICS(note.Body))
+		}
+		categories := []string{escapeICS(note.Project.Name)}
+		if note.Category.Name != "" {
+			categories = append(categories, escapeICS(note.Category.Name))
+		}
+		line("CATEGORIES:" + strings.Join(categories, ","))
+		line("LAST-MODIFIED:" + note.UpdatedAt.UTC().Format(icsTimeLayout))
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+	return b.Flush()
+}
+
+// writeICSLine folds the content line at 75 octets as RFC 5545 asks
+func writeICSLine(w *bufio.Writer, s string) {
+	for len(s) > 75 {
+		cut := 75
+		for cut > 0 && !isRuneStart(s[cut]) {
+			cut-- // do not split a UTF-8 sequence
+		}
+		w.WriteString(s[:cut] + "\r\n ")
+		s = s[cut:]
+	}
+	w.WriteString(s + "\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+func escapeICS(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+func unescapeICS(s string) string {
+	return strings.NewReplacer(
+		`\\`, `\`,
+		`\;`, ";",
+		`\,`, ",",
+		`\n`, "\n",
+		`\N`, "\n",
+	).Replace(s)
+}
+
+func icsDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	h := int(d / time.Hour)
+	m := int((d % time.Hour) / time.Minute)
+	return fmt.Sprintf("PT%dH%dM", h, m)
+}
+
+// parseICSDuration reads the simple durations calendars write, e.g. PT1H30M or P1D
+func parseICSDuration(s string) (time.Duration, error) {
+	s = strings.TrimPrefix(strings.ToUpper(s), "+")
+	if !strings.HasPrefix(s, "P") {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	var d time.Duration
+	inTime := false
+	number := 0
+	for _, r := range s[1:] {
+		switch {
+		case r >= '0' && r <= '9':
+			number = number*10 + int(r-'0')
+		case r == 'T':
+			inTime = true
+		case r == 'W':
+			d += time.Duration(number) * 7 * 24 * time.Hour
+			number = 0
+		case r == 'D':
+			d += time.Duration(number) * 24 * time.Hour
+			number = 0
+		case r == 'H' && inTime:
+			d += time.Duration(number) * time.Hour
+			number = 0
+		case r == 'M' && inTime:
+			d += time.Duration(number) * time.Minute
+			number = 0
+		case r == 'S' && inTime:
+			d += time.Duration(number) * time.Second
+			number = 0
+		default:
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+	}
+	return d, nil
+}
+
+// parseICSTime reads DATE and DATE-TIME values, in UTC, with a TZID or floating
+func parseICSTime(value string, params map[string]string) (time.Time, error) {
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		return time.Parse("20060102", value)
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse(icsTimeLayout, value)
+	}
+
+	location := time.Local
+	if tzid, ok := params["TZID"]; ok {
+		if loc, err := time.LoadLocation(strings.Trim(tzid, `"`)); err == nil {
+			location = loc
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, location)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.UTC(), nil
+}
+
+// ParseICS reads the VEVENTs of a calendar file
+func ParseICS(r io.Reader) ([]ICSEvent, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	// unfold continuation lines first
+	var lines []string
+	for scanner.Scan() {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += text[1:]
+			continue
+		}
+		lines = append(lines, text)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var (
+		events   []ICSEvent
+		event    ICSEvent
+		duration time.Duration
+		inEvent  bool
+		depth    int // nested components such as VALARM
+	)
+
+	for _, text := range lines {
+		nameParams, value, ok := strings.Cut(text, ":")
+		if !ok {
+			continue
+		}
+
+		parts := strings.Split(nameParams, ";")
+		name := strings.ToUpper(parts[0])
+		params := map[string]string{}
+		for _, p := range parts[1:] {
+			if k, v, ok := strings.Cut(p, "="); ok {
+				params[strings.ToUpper(k)] = v
+			}
+		}
+
+		switch {
+		case name == "BEGIN" && strings.ToUpper(value) == "VEVENT":
+			inEvent = true
+			event = ICSEvent{}
+			duration = 0
+			continue
+		case name == "END" && strings.ToUpper(value) == "VEVENT":
+			if event.End.IsZero() && duration > 0 {
+				event.End = event.Start.Add(duration)
+			}
+			if !event.Start.IsZero() {
+				events = append(events, event)
+			}
+			inEvent = false
+			continue
+		case !inEvent:
+			continue
+		case name == "BEGIN":
+			depth++
+			continue
+		case name == "END":
+			depth--
+			continue
+		case depth > 0:
+			continue
+		}
+
+		var err error
+		switch name {
+		case "UID":
+			event.UID = value
+		case "SUMMARY":
+			event.Summary = unescapeICS(value)
+		case "DESCRIPTION":
+			event.Description = unescapeICS(value)
+		case "LOCATION":
+			event.Location = unescapeICS(value)
+		case "DTSTART":
+			event.Start, err = parseICSTime(value, params)
+		case "DTEND":
+			event.End, err = parseICSTime(value, params)
+		case "DURATION":
+			duration, err = parseICSDuration(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("event %q: %s: %w", event.Summary, name, err)
+		}
+	}
+
+	return events, nil
+}
+
+// ImportICS saves the events between from and to as draft notes, the project
+// is guessed from the ics project mappings. Events imported before are skipped.
+func (s *Store) ImportICS(events []ICSEvent, from, to time.Time) (int, error) {
+	imported := 0
+	for _, event := range events {
+		day := event.Start.UTC().Truncate(24 * time.Hour)
+		if (!from.IsZero() && day.Before(from)) || (!to.IsZero() && day.After(to)) {
+			continue
+		}
+
+		id := uuid.NewSHA1(icsNamespace, []byte(event.UID+"|"+event.Start.UTC().Format(icsTimeLayout))).String()
+		existing, err := s.GetNoteById(id)
+		if err != nil {
+			return imported, err
+		}
+		if existing.Id != "" {
+			continue
+		}
+
+		project, err := s.guessProject(mappingICS, event.Summary, event.Description, event.Location)
+		if err != nil {
+			return imported, err
+		}
+		category, err := s.defaultCategory(project.Id)
+		if err != nil {
+			return imported, err
+		}
+
+		note := Note{
+			Id:        id,
+			Title:     event.Summary,
+			Body:      event.Description,
+			CreatedAt: event.Start.UTC(),
+			Draft:     true,
+		}
+		if d := event.Duration(); d > 0 {
+			note.TotalTime = formatDuration(d)
+		}
+
+		if err := s.SaveNoteWithProject(note, project.Id, category.Id, day); err != nil {
+			return imported, err
+		}
+		imported++
+	}
+	return imported, nil
+}
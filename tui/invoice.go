@@ -0,0 +1,377 @@
+package tui
+
+import (
+	"database/sql"
+	"fmt"
+	htmltemplate "html/template"
+	"math"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Rate is the hourly rate of a project, a Category.Id of 0 is the project
+// default and any other category overrides it
+type Rate struct {
+	Project    Project
+	Category   Category
+	HourlyRate float64
+}
+
+// Rounding rounds the logged time of each invoiced note, e.g. up to 15 minutes
+type Rounding struct {
+	To   time.Duration
+	Mode string // up, down or nearest
+}
+
+func (r Rounding) apply(d time.Duration) time.Duration {
+	if r.To <= 0 {
+		return d
+	}
+	switch r.Mode {
+	case "down":
+		return d.Truncate(r.To)
+	case "nearest":
+		return d.Round(r.To)
+	default:
+		if rounded := d.Truncate(r.To); rounded != d {
+			return rounded + r.To
+		}
+		return d
+	}
+}
+
+type InvoiceLine struct {
+	Note   Note
+	Hours  float64
+	Rate   float64
+	Amount float64
+}
+
+type InvoiceGroup struct {
+	Category string
+	Lines    []InvoiceLine
+	Hours    float64
+	Subtotal float64
+}
+
+type Invoice struct {
+	Id        int
+	Number    string
+	Project   Project
+	From, To  time.Time
+	Groups    []InvoiceGroup
+	Hours     float64
+	Total     float64
+	Currency  string
+	CreatedAt time.Time
+}
+
+func (s *Store) initInvoices() error {
+	createTableProjectRatesStmt := `
+        CREATE TABLE IF NOT EXISTS ProjectRates (
+            ProjectId INTEGER NOT NULL,
+            CategoryId INTEGER NOT NULL DEFAULT 0,
+            HourlyRate REAL NOT NULL,
+            PRIMARY KEY (ProjectId, CategoryId),
+            FOREIGN KEY (ProjectId) REFERENCES Projects(Id)
+        );`
+
+	createTableInvoicesStmt := `
+        CREATE TABLE IF NOT EXISTS Invoices (
+            Id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+            Number TEXT NOT NULL DEFAULT '',
+            ProjectId INTEGER NOT NULL,
+            FromDate TEXT NOT NULL,
+            ToDate TEXT NOT NULL,
+            Hours REAL NOT NULL,
+            Total REAL NOT NULL,
+            CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            FOREIGN KEY (ProjectId) REFERENCES Projects(Id)
+        );`
+
+	if _, err := s.conn.Exec(createTableProjectRatesStmt); err != nil {
+		return err
+	}
+
+	if _, err := s.conn.Exec(createTableInvoicesStmt); err != nil {
+		return err
+	}
+
+	if err := s.addColumn("Notes", "Billable", "INTEGER NOT NULL DEFAULT 1"); err != nil {
+		return err
+	}
+
+	return s.addColumn("Notes", "InvoiceId", "INTEGER REFERENCES Invoices(Id)")
+}
+
+func (s *Store) SaveRate(rate Rate) error {
+	if rate.HourlyRate < 0 {
+		return fmt.Errorf("hourly rate must not be negative")
+	}
+
+	upsertQuery := `
+    INSERT INTO ProjectRates (ProjectId, CategoryId, HourlyRate)
+    VALUES (?, ?, ?)
+    ON CONFLICT(ProjectId, CategoryId) DO UPDATE
+    SET HourlyRate=excluded.HourlyRate;`
+
+	_, err := s.conn.Exec(upsertQuery, rate.Project.Id, rate.Category.Id, rate.HourlyRate)
+	return err
+}
+
+func (s *Store) GetRates() ([]Rate, error) {
+	query := `
+        SELECT p.Id, p.Name, r.CategoryId, COALESCE(c.Name, ''), r.HourlyRate
+        FROM ProjectRates r
+        INNER JOIN Projects p ON r.ProjectId = p.Id
+        LEFT JOIN Categories c ON r.CategoryId = c.Id
+        ORDER BY p.Name, r.CategoryId;
+    `
+
+	rows, err := s.conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []Rate
+	for rows.Next() {
+		var r Rate
+		if err := rows.Scan(&r.Project.Id, &r.Project.Name, &r.Category.Id, &r.Category.Name, &r.HourlyRate); err != nil {
+			return nil, err
+		}
+		rates = append(rates, r)
+	}
+	return rates, rows.Err()
+}
+
+// rateFor returns the category rate of the project, or its default rate, and
+// whether either is set
+func (s *Store) rateFor(projectId, categoryId int) (float64, bool, error) {
+	query := `
+        SELECT HourlyRate FROM ProjectRates
+        WHERE ProjectId = ? AND CategoryId IN (?, 0)
+        ORDER BY CategoryId DESC LIMIT 1;
+    `
+
+	var rate float64
+	err := s.conn.QueryRow(query, projectId, categoryId).Scan(&rate)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	return rate, err == nil, err
+}
+
+func (s *Store) SetNoteBillable(noteId string, billable bool) error {
+	_, err := s.conn.Exec("UPDATE Notes SET Billable = ?, UpdatedAt = ? WHERE Id = ?", billable, time.Now().UTC(), noteId)
+	return err
+}
+
+// DraftInvoice collects the billable notes of the project that are not
+// invoiced yet, nothing is saved. Notes without a rate for their category
+// are not priced at 0, the draft fails naming the categories instead.
+func (s *Store) DraftInvoice(project Project, from, to time.Time, rounding Rounding) (Invoice, error) {
+	notes, err := s.GetNotesByDateRange(from, to)
+	if err != nil {
+		return Invoice{}, err
+	}
+
+	invoice := Invoice{Project: project, From: from, To: to}
+	groups := map[string]*InvoiceGroup{}
+	var names, unrated []string
+	seen := map[string]bool{}
+
+	for _, note := range notes {
+		if note.Project.Id != project.Id || !note.Billable || note.InvoiceId != 0 || note.Draft {
+			continue
+		}
+
+		duration := rounding.apply(parseTotalTime(note.TotalTime))
+		if duration == 0 {
+			continue
+		}
+
+		rate, ok, err := s.rateFor(project.Id, note.Category.Id)
+		if err != nil {
+			return Invoice{}, err
+		}
+		if !ok {
+			name := note.Category.Name
+			if name == "" {
+				name = "(no category)"
+			}
+			if !seen[name] {
+				seen[name] = true
+				unrated = append(unrated, name)
+			}
+			continue
+		}
+
+		hours := duration.Hours()
+		line := InvoiceLine{Note: note, Hours: hours, Rate: rate, Amount: roundCents(hours * rate)}
+
+		group, ok := groups[note.Category.Name]
+		if !ok {
+			group = &InvoiceGroup{Category: note.Category.Name}
+			groups[note.Category.Name] = group
+			names = append(names, note.Category.Name)
+		}
+		group.Lines = append(group.Lines, line)
+		group.Hours += line.Hours
+		group.Subtotal += line.Amount
+
+		invoice.Hours += line.Hours
+		invoice.Total += line.Amount
+	}
+
+	if len(unrated) > 0 {
+		sort.Strings(unrated)
+		return Invoice{}, fmt.Errorf("project %s has no hourly rate for %s, set a default or category rate with notes rate",
+			project.Name, strings.Join(unrated, ", "))
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		group := groups[name]
+		group.Subtotal = roundCents(group.Subtotal)
+		invoice.Groups = append(invoice.Groups, *group)
+	}
+	invoice.Total = roundCents(invoice.Total)
+	return invoice, nil
+}
+
+// SaveInvoice gives the invoice the next sequence number and marks its notes as invoiced
+func (s *Store) SaveInvoice(invoice *Invoice) error {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	invoice.CreatedAt = time.Now().UTC()
+	result, err := tx.Exec(`
+        INSERT INTO Invoices (ProjectId, FromDate, ToDate, Hours, Total, CreatedAt)
+        VALUES (?, ?, ?, ?, ?, ?);`,
+		invoice.Project.Id, invoice.From.Format(dateLayout), invoice.To.Format(dateLayout),
+		invoice.Hours, invoice.Total, invoice.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	invoice.Id = int(id)
+	invoice.Number = fmt.Sprintf("%s-%04d", invoice.CreatedAt.Format("2006"), id)
+
+	if _, err := tx.Exec("UPDATE Invoices SET Number = ? WHERE Id = ?", invoice.Number, invoice.Id); err != nil {
+		return err
+	}
+
+	for _, group := range invoice.Groups {
+		for _, line := range group.Lines {
+			if _, err := tx.Exec("UPDATE Notes SET InvoiceId = ? WHERE Id = ?", invoice.Id, line.Note.Id); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+var invoiceFuncs = map[string]any{
+	"date":  func(t time.Time) string { return t.Format(dateLayout) },
+	"money": func(v float64) string { return fmt.Sprintf("%.2f", v) },
+	"hours": func(v float64) string { return fmt.Sprintf("%.2f", v) },
+}
+
+const invoiceMarkdownTemplate = `# Invoice {{if .Number}}{{.Number}}{{else}}(draft){{end}}
+
+- **Project:** {{.Project.Name}}
+- **Period:** {{date .From}} to {{date .To}}
+{{- if not .CreatedAt.IsZero}}
+- **Issued:** {{date .CreatedAt}}
+{{- end}}
+{{range .Groups}}
+## {{if .Category}}{{.Category}}{{else}}Uncategorised{{end}}
+
+| Date | Item | Hours | Rate | Amount |
+| ---- | ---- | ----: | ---: | -----: |
+{{- range .Lines}}
+| {{date .Note.CreatedAt}} | {{.Note.Title}} | {{hours .Hours}} | {{money .Rate}} | {{money .Amount}} |
+{{- end}}
+| | **Subtotal** | **{{hours .Hours}}** | | **{{money .Subtotal}}** |
+{{end}}
+- **Total hours:** {{hours .Hours}}
+- **Total:** {{money .Total}} {{.Currency}}
+`
+
+const invoiceHTMLTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Invoice {{.Number}}</title>
+<style>
+body { font-family: sans-serif; max-width: 48em; margin: 2em auto; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1.5em; }
+th, td { border-bottom: 1px solid #ddd; padding: .4em; text-align: left; }
+td.num, th.num { text-align: right; }
+tr.subtotal td { font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>Invoice {{if .Number}}{{.Number}}{{else}}(draft){{end}}</h1>
+<p>
+<strong>Project:</strong> {{.Project.Name}}<br>
+<strong>Period:</strong> {{date .From}} to {{date .To}}
+{{- if not .CreatedAt.IsZero}}<br>
+<strong>Issued:</strong> {{date .CreatedAt}}{{end}}
+</p>
+{{range .Groups}}
+<h2>{{if .Category}}{{.Category}}{{else}}Uncategorised{{end}}</h2>
+<table>
+<tr><th>Date</th><th>Item</th><th class="num">Hours</th><th class="num">Rate</th><th class="num">Amount</th></tr>
+{{- range .Lines}}
+<tr><td>{{date .Note.CreatedAt}}</td><td>{{.Note.Title}}</td><td class="num">{{hours .Hours}}</td><td class="num">{{money .Rate}}</td><td class="num">{{money .Amount}}</td></tr>
+{{- end}}
+<tr class="subtotal"><td></td><td>Subtotal</td><td class="num">{{hours .Hours}}</td><td></td><td class="num">{{money .Subtotal}}</td></tr>
+</table>
+{{end}}
+<p><strong>Total hours:</strong> {{hours .Hours}}<br>
+<strong>Total:</strong> {{money .Total}} {{.Currency}}</p>
+</body>
+</html>
+`
+
+func (invoice Invoice) Markdown() (string, error) {
+	tmpl, err := template.New("invoice").Funcs(invoiceFuncs).Parse(invoiceMarkdownTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, invoice); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func (invoice Invoice) HTML() (string, error) {
+	tmpl, err := htmltemplate.New("invoice").Funcs(invoiceFuncs).Parse(invoiceHTMLTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, invoice); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
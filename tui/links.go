@@ -0,0 +1,403 @@
+package tui
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// linkPattern matches [[Note title]] and [[note id]] in note bodies
+var linkPattern = regexp.MustCompile(`\[\[([^\[\]\n]+)\]\]`)
+
+// maxFollowLinks is how many links of the detail view have a number key
+const maxFollowLinks = 9
+
+// parseLinks returns the link targets of body, each once
+func parseLinks(body string) []string {
+	var targets []string
+	seen := map[string]bool{}
+	for _, match := range linkPattern.FindAllStringSubmatch(body, -1) {
+		target := strings.TrimSpace(match[1])
+		if target == "" || seen[strings.ToLower(target)] {
+			continue
+		}
+		seen[strings.ToLower(target)] = true
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// resolveLink finds the note a link of from points to: the note with that id,
+// else the note with that title. Of several notes with the title the newest
+// one not after from wins, so [[Standup]] means the last standup before.
+func resolveLink(target string, from Note, notes []Note) (Note, bool) {
+	for _, note := range notes {
+		if note.Id == target {
+			return note, true
+		}
+	}
+
+	var before, after Note
+	for _, note := range notes {
+		if note.Id == from.Id || !strings.EqualFold(strings.TrimSpace(note.Title), target) {
+			continue
+		}
+		if !note.CreatedAt.After(from.CreatedAt) {
+			if before.Id == "" || note.CreatedAt.After(before.CreatedAt) {
+				before = note
+			}
+		} else if after.Id == "" || note.CreatedAt.Before(after.CreatedAt) {
+			after = note
+		}
+	}
+	if before.Id != "" {
+		return before, true
+	}
+	return after, after.Id != ""
+}
+
+// resolveLinks returns the notes the body of from links to, in link order
+func resolveLinks(from Note, notes []Note) []Note {
+	var linked []Note
+	seen := map[string]bool{}
+	for _, target := range parseLinks(from.Body) {
+		if note, ok := resolveLink(target, from, notes); ok && !seen[note.Id] {
+			seen[note.Id] = true
+			linked = append(linked, note)
+		}
+	}
+	return linked
+}
+
+// initLinks creates the NoteLinks table. Only resolved ids are kept so the
+// link text of encrypted notes is not stored in the clear. A new table is
+// filled from the existing notes, so while they are locked it is left for
+// Unlock to create.
+func (s *Store) initLinks() error {
+	var exists int
+	err := s.conn.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'NoteLinks';").Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists == 0 && s.Locked() {
+		return nil
+	}
+
+	createTableNoteLinksStmt := `
+        CREATE TABLE IF NOT EXISTS NoteLinks (
+            FromId TEXT NOT NULL,
+            ToId TEXT NOT NULL,
+            PRIMARY KEY (FromId, ToId)
+        );`
+
+	createIndexNoteLinksToStmt := `CREATE INDEX IF NOT EXISTS NoteLinksToId ON NoteLinks (ToId);`
+
+	if _, err := s.conn.Exec(createTableNoteLinksStmt); err != nil {
+		return err
+	}
+	if _, err := s.conn.Exec(createIndexNoteLinksToStmt); err != nil {
+		return err
+	}
+
+	if exists == 0 {
+		return s.rebuildLinks()
+	}
+	return nil
+}
+
+func (s *Store) rebuildLinks() error {
+	notes, err := s.GetNotes()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, note := range notes {
+		if err := saveLinks(tx, note.Id, resolveLinks(note, notes)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// noteLinks resolves the links of a note about to be saved on date
+func (s *Store) noteLinks(note Note, date time.Time) ([]Note, error) {
+	if len(parseLinks(note.Body)) == 0 {
+		return nil, nil
+	}
+	if note.CreatedAt.IsZero() {
+		note.CreatedAt = onDate(date, time.Now())
+	}
+
+	return s.Links(note)
+}
+
+// Links returns the notes the body of note links to, in link order
+func (s *Store) Links(note Note) ([]Note, error) {
+	candidates, err := s.linkCandidates(parseLinks(note.Body))
+	if err != nil {
+		return nil, err
+	}
+	return resolveLinks(note, candidates), nil
+}
+
+// linkCandidates returns the notes links to targets may resolve to: the notes
+// with such an id, else the notes with such a title. Titles are matched on the
+// Id and Title columns alone, so no other body is loaded or decrypted.
+func (s *Store) linkCandidates(targets []string) ([]Note, error) {
+	candidates, err := s.notesById(targets)
+	if err != nil {
+		return nil, err
+	}
+
+	found := map[string]bool{}
+	for _, note := range candidates {
+		found[note.Id] = true
+	}
+	var titles []string
+	for _, target := range targets {
+		if !found[target] {
+			titles = append(titles, target)
+		}
+	}
+	if len(titles) == 0 {
+		return candidates, nil
+	}
+
+	rows, err := s.conn.Query("SELECT Id, Title FROM Notes;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id, title string
+		if err := rows.Scan(&id, &title); err != nil {
+			return nil, err
+		}
+		if title, err = s.open(title, id, "Title"); err != nil {
+			return nil, err
+		}
+		for _, target := range titles {
+			if strings.EqualFold(strings.TrimSpace(title), target) {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	titled, err := s.notesById(ids)
+	if err != nil {
+		return nil, err
+	}
+	return append(candidates, titled...), nil
+}
+
+// notesById returns the notes with the ids that exist
+func (s *Store) notesById(ids []string) ([]Note, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := s.conn.Query(noteQuery+"WHERE n.Id IN (?"+strings.Repeat(", ?", len(ids)-1)+");", args...)
+	if err != nil {
+		return nil, err
+	}
+	return s.scanNotes(rows)
+}
+
+// linkTarget returns the id, title and date of the note, what links to it
+// are resolved on, or a zero Note when it does not exist
+func (s *Store) linkTarget(noteId string) (Note, error) {
+	note := Note{Id: noteId}
+	err := s.conn.QueryRow("SELECT Title, CreatedAt FROM Notes WHERE Id = ?;", noteId).Scan(&note.Title, &note.CreatedAt)
+	if err == sql.ErrNoRows {
+		return Note{}, nil
+	} else if err != nil {
+		return Note{}, err
+	}
+	note.Title, err = s.open(note.Title, noteId, "Title")
+	return note, err
+}
+
+// relinkChanged resolves the links to a saved note again when it is new, or
+// its title or date differ from before
+func (s *Store) relinkChanged(before, after Note) error {
+	if before.Id != "" && before.CreatedAt.Equal(after.CreatedAt) &&
+		strings.EqualFold(strings.TrimSpace(before.Title), strings.TrimSpace(after.Title)) {
+		return nil
+	}
+	return s.relink(after.Id, before.Title, after.Title)
+}
+
+// relink resolves the links of the notes linking to one of targets again.
+// Targets are the id and titles of a note that was added, renamed, moved or
+// deleted, after which a [[title]] may mean another note or none.
+func (s *Store) relink(targets ...string) error {
+	linking, err := s.linkingNotes(targets)
+	if err != nil || len(linking) == 0 {
+		return err
+	}
+
+	links := make([][]Note, len(linking))
+	for i, note := range linking {
+		if links[i], err = s.Links(note); err != nil {
+			return err
+		}
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i, note := range linking {
+		if err := saveLinks(tx, note.Id, links[i]); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// linkingNotes returns the notes whose body links to one of targets. Only
+// bodies with a link are read, though every encrypted one has to be opened.
+func (s *Store) linkingNotes(targets []string) ([]Note, error) {
+	// sealed values are base64, which has no brackets
+	rows, err := s.conn.Query("SELECT Id, Body FROM Notes WHERE instr(Body, '[[') > 0 OR Body LIKE ?;", encryptedPrefix+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id, body string
+		if err := rows.Scan(&id, &body); err != nil {
+			return nil, err
+		}
+		if body, err = s.open(body, id, "Body"); err != nil {
+			return nil, err
+		}
+		if linksTo(body, targets) {
+			ids = append(ids, id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return s.notesById(ids)
+}
+
+// linksTo tells whether body has a link to one of targets
+func linksTo(body string, targets []string) bool {
+	for _, link := range parseLinks(body) {
+		for _, target := range targets {
+			if target = strings.TrimSpace(target); target != "" && strings.EqualFold(link, target) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// saveLinks replaces the links of the note
+func saveLinks(tx *sql.Tx, fromId string, linked []Note) error {
+	if _, err := tx.Exec("DELETE FROM NoteLinks WHERE FromId = ?;", fromId); err != nil {
+		return err
+	}
+	for _, note := range linked {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO NoteLinks (FromId, ToId) VALUES (?, ?);", fromId, note.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Backlinks returns the notes linking to the note, oldest first
+func (s *Store) Backlinks(noteId string) ([]Note, error) {
+	rows, err := s.conn.Query(noteQuery+"WHERE n.Id IN (SELECT FromId FROM NoteLinks WHERE ToId = ?) ORDER BY n.CreatedAt;", noteId)
+	if err != nil {
+		return nil, err
+	}
+	return s.scanNotes(rows)
+}
+
+// showNote opens the detail view of the note with its links and backlinks
+// numbered for following
+func (m *model) showNote(note Note) error {
+	// backlinks are kept by the sqlite backend only, the others resolve
+	// links against every note
+	var links, backlinks []Note
+	if store, err := m.sqlite(); err == nil {
+		if links, err = store.Links(note); err != nil {
+			return err
+		}
+		if backlinks, err = store.Backlinks(note.Id); err != nil {
+			return err
+		}
+	} else {
+		notes, err := m.store.GetNotes()
+		if err != nil {
+			return err
+		}
+		links = resolveLinks(note, notes)
+	}
+
+	m.links = nil
+	section := func(title string, linked []Note) string {
+		if len(linked) == 0 {
+			return ""
+		}
+		s := "\n## " + title + "\n\n"
+		for _, n := range linked {
+			m.links = append(m.links, n)
+			key := " "
+			if len(m.links) <= maxFollowLinks {
+				key = fmt.Sprint(len(m.links))
+			}
+			s += fmt.Sprintf("- [%s] %s · %s\n", key, n.Title, n.CreatedAt.Format(dateLayout))
+		}
+		return s
+	}
+
+	content := noteMarkdown(note) + section("Links", links) + section("Backlinks", backlinks)
+
+	// attachments are kept by the sqlite backend only
+	if store, err := m.sqlite(); err == nil {
+		attachments, err := store.Attachments(note.Id)
+		if err != nil {
+			return err
+		}
+		if len(attachments) > 0 {
+			content += "\n## Attachments\n\n"
+			for _, a := range attachments {
+				content += "- " + a.String() + "\n"
+			}
+		}
+	}
+	return m.showMarkdown("note", content)
+}
+
+// followLink goes to the note of the numbered link
+func (m *model) followLink(key string) error {
+	var i int
+	if _, err := fmt.Sscan(key, &i); err != nil || i < 1 || i > len(m.links) || i > maxFollowLinks {
+		return nil
+	}
+	return m.goToNote(m.links[i-1])
+}
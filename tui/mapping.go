@@ -0,0 +1,114 @@
+package tui
+
+import (
+	"strings"
+)
+
+const (
+	mappingICS = "ics"
+	mappingGit = "git"
+)
+
+// fallbackProject receives imported notes no mapping matches
+const fallbackProject = "General"
+
+// ProjectMapping sends imported items whose text contains Pattern to a project
+type ProjectMapping struct {
+	Kind        string
+	Pattern     string
+	ProjectName string
+}
+
+func (s *Store) initMappings() error {
+	createTableProjectMappingsStmt := `
+        CREATE TABLE IF NOT EXISTS ProjectMappings (
+            Kind TEXT NOT NULL,
+            Pattern TEXT NOT NULL,
+            ProjectName TEXT NOT NULL,
+            PRIMARY KEY (Kind, Pattern)
+        );`
+
+	_, err := s.conn.Exec(createTableProjectMappingsStmt)
+	return err
+}
+
+func (s *Store) SaveProjectMapping(mapping ProjectMapping) error {
+	upsertQuery := `
+    INSERT INTO ProjectMappings (Kind, Pattern, ProjectName)
+    VALUES (?, ?, ?)
+    ON CONFLICT(Kind, Pattern) DO UPDATE
+    SET ProjectName=excluded.ProjectName;`
+
+	_, err := s.conn.Exec(upsertQuery, mapping.Kind, mapping.Pattern, mapping.ProjectName)
+	return err
+}
+
+func (s *Store) DeleteProjectMapping(kind, pattern string) error {
+	_, err := s.conn.Exec("DELETE FROM ProjectMappings WHERE Kind = ? AND Pattern = ?", kind, pattern)
+	return err
+}
+
+func (s *Store) GetProjectMappings(kind string) ([]ProjectMapping, error) {
+	rows, err := s.conn.Query(
+		"SELECT Kind, Pattern, ProjectName FROM ProjectMappings WHERE Kind = ? ORDER BY length(Pattern) DESC, Pattern", kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mappings []ProjectMapping
+	for rows.Next() {
+		var m ProjectMapping
+		if err := rows.Scan(&m.Kind, &m.Pattern, &m.ProjectName); err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, rows.Err()
+}
+
+// guessProject returns the project of the first (longest) mapping whose pattern
+// appears in one of texts, or the fallback project
+func (s *Store) guessProject(kind string, texts ...string) (Project, error) {
+	mappings, err := s.GetProjectMappings(kind)
+	if err != nil {
+		return Project{}, err
+	}
+
+	name := fallbackProject
+	for _, m := range mappings {
+		if containsFold(texts, m.Pattern) {
+			name = m.ProjectName
+			break
+		}
+	}
+
+	project, err := s.GetProjectByName(name)
+	if err != nil {
+		return Project{}, err
+	}
+	if project.Id == 0 {
+		// mapping to a deleted project, fall back
+		return s.GetProjectByName(fallbackProject)
+	}
+	return project, nil
+}
+
+func containsFold(texts []string, pattern string) bool {
+	pattern = strings.ToLower(pattern)
+	for _, text := range texts {
+		if strings.Contains(strings.ToLower(text), pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultCategory returns the first category of the project, zero if it has none
+func (s *Store) defaultCategory(projectId int) (Category, error) {
+	categories, err := s.GetCategoriesByProject(projectId)
+	if err != nil || len(categories) == 0 {
+		return Category{}, err
+	}
+	return categories[0], nil
+}
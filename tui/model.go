@@ -8,7 +8,10 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 
+	"github.com/charmbracelet/bubbles/filepicker"
+	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/table"
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
@@ -23,14 +26,35 @@ const (
 	projectSelectView
 	projectCategoiesView
 	summaryNoteToday
+	dateSelectView
+	recurrenceView
+	templateSelectView
+	standupView
+	noteDetailView
+	budgetView
+	conflictView
+	mergeView
+	pomodoroView
+	attachmentsView
+	attachPickView
+	noteTasksView
+	openTasksView
+)
+
+const (
+	dateActionMove uint = iota
+	dateActionCopy
 )
 
 type model struct {
 	state         uint
-	store         *Store
+	store         Storage
+	config        Config
 	notes         []Note
 	currNote      Note
 	listIndex     int
+	noteTable     table.Model
+	sortBy        uint
 	textArea      textarea.Model
 	textInput     textinput.Model
 	textInputTime textinput.Model
@@ -51,6 +75,48 @@ type model struct {
 	currentDate time.Time // Tracks the displayed date
 
 	summaryNoteViewport viewport.Model
+	viewportText        string // raw markdown shown in the viewport, used by copy
+	viewportName        string
+
+	dateInput  textinput.Model
+	dateAction uint
+	dateErr    error
+
+	statusMsg string
+
+	recurrences      []Recurrence
+	recurrenceCursor int
+	ruleInput        textinput.Model
+	isAddingRule     bool
+	ruleErr          error
+
+	templates      []Template
+	templateCursor int
+	currTemplate   Template
+
+	budgetStatuses []BudgetStatus
+
+	progressBar progress.Model // budgets and daily target
+
+	dataVersion int64 // last seen version of the store, see pollChanges
+
+	conflict *ConflictError // the save that lost, see conflictView
+
+	pomodoro pomodoroState
+
+	links []Note // linked notes of the detail view, numbered from 1
+
+	attachments      []Attachment // of the note in attachmentsView
+	attachmentCursor int
+	filePicker       filepicker.Model
+
+	tasks      []Task // checklist items of noteTasksView and openTasksView
+	taskCursor int
+
+	carryFrom  time.Time // previous working day with open items
+	carryCount int       // its open items, 0 when there is nothing to offer
+
+	width, height int // of the terminal, for full-screen views
 }
 
 // Custom message for loading notes
@@ -66,11 +132,21 @@ type deleteCompleteMsg struct {
 	notes []Note
 }
 
-func NewModel(store *Store) model {
+// actionCompleteMsg reloads the list after an action and reports it in the status line
+type actionCompleteMsg struct {
+	notes  []Note
+	status string
+}
+
+type errMsg struct {
+	err error
+}
+
+func NewModel(store Storage, config Config) model {
 	today := time.Now().Truncate(24 * time.Hour)
 
 	//notes, err := store.GetNotes()
-	notes, err := store.GetNotesByDate(today)
+	notes, err := loadNotes(store, today)
 
 	if err != nil {
 		log.Fatalf("unable to get notes: %v", err)
@@ -109,10 +185,12 @@ func NewModel(store *Store) model {
 	spin := spinner.New()
 	spin.Spinner = spinner.Dot
 
-	return model{
+	m := model{
 		state:               listView,
 		store:               store,
-		notes:               notes,
+		config:              config,
+		noteTable:           newNoteTable(),
+		sortBy:              sortByCreated,
 		textArea:            textarea.New(),
 		textInput:           textinput.New(),
 		spinner:             spin,
@@ -121,11 +199,24 @@ func NewModel(store *Store) model {
 		projects:            projects,
 		currentDate:         today,
 		summaryNoteViewport: vp,
+		dateInput:           textinput.New(),
+		ruleInput:           textinput.New(),
+		progressBar:         progress.New(progress.WithDefaultGradient(), progress.WithWidth(30)),
+	}
+	m.ruleInput.Placeholder = "FREQ=WEEKLY;BYDAY=MO"
+	m.dateInput.Placeholder = dateLayout
+	m.setNotes(notes)
+	m.checkCarryOver()
+
+	if watcher, ok := store.(changeWatcher); ok {
+		m.dataVersion, _ = watcher.DataVersion()
 	}
+
+	return m
 }
 
 func (m model) Init() tea.Cmd {
-	return m.spinner.Tick
+	return tea.Batch(m.spinner.Tick, m.pollChanges())
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -146,7 +237,23 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	m.summaryNoteViewport, cmd = m.summaryNoteViewport.Update(msg)
 	cmds = append(cmds, cmd)
 
+	m.dateInput, cmd = m.dateInput.Update(msg)
+	cmds = append(cmds, cmd)
+
+	m.ruleInput, cmd = m.ruleInput.Update(msg)
+	cmds = append(cmds, cmd)
+
+	// the file picker reads folders with messages of its own
+	if _, ok := msg.(tea.KeyMsg); !ok && m.state == attachPickView {
+		m.filePicker, cmd = m.filePicker.Update(msg)
+		cmds = append(cmds, cmd)
+	}
+
 	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.resizeNoteTable(msg.Width, msg.Height)
+
 	case spinner.TickMsg:
 		if m.isLoading {
 			m.spinner, cmd = m.spinner.Update(msg)
@@ -155,15 +262,16 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case notesLoadedMsg:
 		// Update notes after loading completes
-		m.notes = msg.notes
+		m.setNotes(msg.notes)
 		m.isLoading = false
 
 	case saveCompleteMsg:
 		// Handle save completion
-		m.notes = msg.notes
+		m.setNotes(msg.notes)
 		m.isLoading = false
 		m.isEditing = false
 		m.currNote = Note{}
+		m.currTemplate = Template{}
 		// reset currProject
 		// or m.currProject = Project{}
 		if len(m.projects) > 0 {
@@ -177,15 +285,63 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.state = listView
 
 	case deleteCompleteMsg:
-		m.notes = msg.notes
+		deletedIndex := m.listIndex
+		m.setNotes(msg.notes)
+		m.isLoading = false
+
+		// the deleted note is gone, stay at the same row
+		if deletedIndex >= len(m.notes) {
+			deletedIndex = len(m.notes) - 1 // adjust to the last note if need
+		}
+		if deletedIndex >= 0 {
+			m.listIndex = deletedIndex
+			m.noteTable.SetCursor(m.listIndex)
+		}
+
+	case actionCompleteMsg:
+		m.setNotes(msg.notes)
+		m.isLoading = false
+		m.statusMsg = msg.status
+		m.checkCarryOver()
+
+	case errMsg:
+		m.isLoading = false
+		m.statusMsg = "error: " + msg.err.Error()
+
+	case dataVersionMsg:
+		cmds = append(cmds, m.pollChanges())
+		if msg.err == nil && msg.version != m.dataVersion {
+			m.dataVersion = msg.version
+			cmds = append(cmds, m.reloadChanged())
+		}
+
+	case pomodoroTickMsg:
+		m, cmd = m.updatePomodoroTick(msg)
+		cmds = append(cmds, cmd)
+
+	case pomodoroSavedMsg:
+		m.setNotes(msg.notes)
+		m.statusMsg = msg.status
+		for _, note := range msg.notes {
+			if note.Id == m.pomodoro.note.Id {
+				m.pomodoro.note = note
+			}
+		}
+
+	case conflictMsg:
 		m.isLoading = false
+		m.conflict = msg.conflict
+		m.state = conflictView
 
-		if m.listIndex >= len(m.notes) && len(m.notes) > 0 {
-			m.listIndex = len(m.notes) - 1 // adjust to the last note if need
+	case notesChangedMsg:
+		// a day switch or a running action already loads newer notes
+		if msg.date.Equal(m.currentDate) && !m.isLoading {
+			m.setNotes(msg.notes)
 		}
 
 	case tea.KeyMsg:
 		key := msg.String() //up, down, etc ...
+		m.statusMsg = ""
 		switch m.state {
 		case listView:
 			if m.isLoading {
@@ -199,25 +355,107 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.textInput.SetValue("")
 				m.textInput.Focus()
 				m.currNote = Note{}
+				m.currTemplate = Template{}
 				m.state = titleView
-			case "up", "k":
-				if m.listIndex > 0 {
-					m.listIndex--
+			case "t": // New note from a template
+				store, err := m.sqlite()
+				if err != nil {
+					m.statusMsg = "error: " + err.Error()
+					break
 				}
-			case "down", "j":
-				if m.listIndex < len(m.notes)-1 {
-					m.listIndex++
+				templates, err := store.GetTemplates()
+				if err != nil {
+					m.statusMsg = "error: " + err.Error()
+					break
+				}
+				m.templates = templates
+				m.templateCursor = 0
+				m.state = templateSelectView
+			case "T": // Save the selected note as a template
+				if note, ok := m.selectedNote(); ok {
+					t := Template{Name: note.Title, Body: note.Body, Project: note.Project, Category: note.Category}
+					store, err := m.sqlite()
+					if err == nil {
+						err = store.SaveTemplate(t)
+					}
+					if err != nil {
+						m.statusMsg = "error: " + err.Error()
+						break
+					}
+					m.statusMsg = fmt.Sprintf("saved template %q", t.Name)
+				}
+			case "m", "c": // Move or duplicate the selected note to another date
+				if note, ok := m.selectedNote(); ok && !note.isPlaceholder() {
+					m.dateAction = dateActionMove
+					if key == "c" {
+						m.dateAction = dateActionCopy
+					}
+					m.dateErr = nil
+					m.dateInput.SetValue(m.currentDate.Format(dateLayout))
+					m.dateInput.Focus()
+					m.dateInput.CursorEnd()
+					m.state = dateSelectView
+				}
+			case "C": // Carry the open items of the previous working day over
+				if m.carryCount == 0 {
+					break
 				}
+				from := m.carryFrom
+				to := m.currentDate
+				m.isLoading = true
+				return m, tea.Batch(
+					m.spinner.Tick,
+					func() tea.Msg {
+						n, err := CarryOver(m.store, from, to)
+						if err != nil {
+							return errMsg{err}
+						}
+						notes, err := loadNotes(m.store, to)
+						if err != nil {
+							return errMsg{err}
+						}
+						return actionCompleteMsg{
+							notes:  notes,
+							status: fmt.Sprintf("carried %d open items over from %s", n, from.Format(dateLayout)),
+						}
+					},
+				)
+			case "Y": // Copy the notes of the previous day onto this day
+				from := m.currentDate.AddDate(0, 0, -1)
+				to := m.currentDate
+				m.isLoading = true
+				return m, tea.Batch(
+					m.spinner.Tick,
+					func() tea.Msg {
+						n, err := m.store.CopyNotesByDate(from, to)
+						if err != nil {
+							return errMsg{err}
+						}
+						notes, err := loadNotes(m.store, to)
+						if err != nil {
+							return errMsg{err}
+						}
+						return actionCompleteMsg{
+							notes:  notes,
+							status: fmt.Sprintf("copied %d notes from %s", n, from.Format(dateLayout)),
+						}
+					},
+				)
+			case "s":
+				m.sortBy = (m.sortBy + 1) % uint(len(sortNames))
+				m.setNotes(m.notes)
 			case "enter":
-				m.currNote = m.notes[m.listIndex]
-				m.textArea.SetValue(m.currNote.Body)
-				m.textInputTime.SetValue(m.currNote.TotalTime)
-				m.textArea.Focus()
-				m.textArea.CursorEnd()
-
-				m.isEditing = m.currNote.Id != "" // Set if editing
-
-				m.state = bodyView
+				if note, ok := m.selectedNote(); ok {
+					m.startEditing(note)
+				}
+			case "v": // Read the selected note
+				if note, ok := m.selectedNote(); ok {
+					if err := m.showNote(note); err != nil {
+						m.statusMsg = "error: " + err.Error()
+						break
+					}
+					m.state = noteDetailView
+				}
 
 			case "r":
 				m.isLoading = true
@@ -227,7 +465,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					func() tea.Msg {
 						// Simulate a delay (e.g., fetching notes)
 						time.Sleep(1 * time.Second)
-						newNotes, err := m.store.GetNotesByDate(m.currentDate)
+						newNotes, err := loadNotes(m.store, m.currentDate)
 						if err != nil {
 							// Handle error (for simplicity, quit)
 							return tea.Quit
@@ -235,18 +473,106 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						return notesLoadedMsg{notes: newNotes}
 					},
 				)
+			case "a": // Accept the selected recurring placeholder or draft as is
+				if note, ok := m.selectedNote(); ok && note.Draft {
+					if err := m.store.SetNoteDraft(note.Id, false); err != nil {
+						m.statusMsg = "error: " + err.Error()
+						break
+					}
+					m.refreshSelected(note.Id)
+					m.statusMsg = fmt.Sprintf("accepted %q", note.Title)
+				}
+				if note, ok := m.selectedNote(); ok && note.isPlaceholder() {
+					currentDate := m.currentDate
+					m.isLoading = true
+					return m, tea.Batch(
+						m.spinner.Tick,
+						func() tea.Msg {
+							err := m.store.SaveNoteWithProject(note, note.Project.Id, note.Category.Id, currentDate)
+							if err != nil {
+								return errMsg{err}
+							}
+							notes, err := loadNotes(m.store, currentDate)
+							if err != nil {
+								return errMsg{err}
+							}
+							return actionCompleteMsg{notes: notes, status: fmt.Sprintf("accepted %q", note.Title)}
+						},
+					)
+				}
+			case "p": // Pomodoro on the selected note
+				if note, ok := m.selectedNote(); ok && !note.isPlaceholder() {
+					if note.InvoiceId != 0 {
+						m.statusMsg = "note is already invoiced"
+						break
+					}
+					m.pomodoro.completed = 0
+					return m.startPomodoro(note)
+				}
+			case "x": // Skip the selected recurring placeholder for this day
+				if note, ok := m.selectedNote(); ok && note.isPlaceholder() {
+					return m, m.skipRecurrence(note)
+				}
+			case "b": // Toggle billable on the selected note
+				if note, ok := m.selectedNote(); ok && !note.isPlaceholder() {
+					if note.InvoiceId != 0 {
+						m.statusMsg = "note is already invoiced"
+						break
+					}
+					if err := m.store.SetNoteBillable(note.Id, !note.Billable); err != nil {
+						m.statusMsg = "error: " + err.Error()
+						break
+					}
+					m.refreshSelected(note.Id)
+				}
+			case "B":
+				store, err := m.sqlite()
+				if err != nil {
+					m.statusMsg = "error: " + err.Error()
+					break
+				}
+				statuses, err := store.GetBudgetStatuses(m.currentDate)
+				if err != nil {
+					m.statusMsg = "error: " + err.Error()
+					break
+				}
+				m.budgetStatuses = statuses
+				m.state = budgetView
+			case "O": // Open tasks of all days
+				m.taskCursor = 0
+				if err := m.showOpenTasks(); err != nil {
+					m.statusMsg = "error: " + err.Error()
+				}
+			case "R":
+				store, err := m.sqlite()
+				if err != nil {
+					m.statusMsg = "error: " + err.Error()
+					break
+				}
+				recurrences, err := store.GetRecurrences()
+				if err != nil {
+					m.statusMsg = "error: " + err.Error()
+					break
+				}
+				m.recurrences = recurrences
+				m.recurrenceCursor = 0
+				m.isAddingRule = false
+				m.state = recurrenceView
 			case "d": // Delete the seletced note
-				if len(m.notes) > 0 && m.listIndex < len(m.notes) {
+				if note, ok := m.selectedNote(); ok && note.isPlaceholder() {
+					return m, m.skipRecurrence(note)
+				}
+				if note, ok := m.selectedNote(); ok {
 					m.isLoading = true
 					return m, tea.Batch(
 						m.spinner.Tick,
 						func() tea.Msg {
-							err := m.store.DeleteNote(m.notes[m.listIndex].Id)
+							err := m.store.DeleteNote(note.Id)
 							if err != nil {
 								// Handle error
 								return tea.Quit()
 							}
-							updatedNotes, err := m.store.GetNotesByDate(m.currentDate)
+							updatedNotes, err := loadNotes(m.store, m.currentDate)
 							if err != nil {
 								return tea.Quit()
 							}
@@ -261,59 +587,276 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				m.currentDate = m.currentDate.AddDate(0, 0, 1)
 				//m.filteredNotes = filterNotesByDate(m.notes, m.currentDate)
-				notes, err := m.store.GetNotesByDate(m.currentDate)
+				notes, err := loadNotes(m.store, m.currentDate)
 				if err != nil {
 					// handle error ...
 				}
-				m.notes = notes
+				m.setNotes(notes)
+				m.checkCarryOver()
 			case "ctrl+p":
 				m.currentDate = m.currentDate.AddDate(0, 0, -1)
-				notes, err := m.store.GetNotesByDate(m.currentDate)
+				notes, err := loadNotes(m.store, m.currentDate)
 				if err != nil {
 					// handle error ...
 				}
-				m.notes = notes
+				m.setNotes(notes)
 			case "ctrl+g":
 				today := time.Now().Truncate(24 * time.Hour)
 				m.currentDate = today
-				notes, err := m.store.GetNotesByDate(m.currentDate)
+				notes, err := loadNotes(m.store, m.currentDate)
 				if err != nil {
 					// handle error ...
 				}
-				m.notes = notes
+				m.setNotes(notes)
+				m.checkCarryOver()
+			case "u": // Standup report of the displayed day
+				report, err := StandupReport(m.store, m.currentDate, m.config.StandupTemplate)
+				if err != nil {
+					m.statusMsg = "error: " + err.Error()
+					break
+				}
+				if err := m.showMarkdown("standup", report); err != nil {
+					m.statusMsg = "error: " + err.Error()
+					break
+				}
+				m.state = standupView
 			case "ctrl+s":
+				notes, _ := m.store.GetNotesByDate(m.currentDate)
+				// pomodoros are kept by the sqlite backend only
+				var pomodoros map[string]int
+				if store, err := m.sqlite(); err == nil {
+					pomodoros, _ = store.PomodoroCounts(m.currentDate)
+				}
+				content := generateNoteSummaryContent(notes, pomodoros)
+				if err := m.showMarkdown("summary", content); err != nil {
+					log.Fatal("unable to render glamour viewport", err)
+				}
 
-				renderer, err := glamour.NewTermRenderer(
-					glamour.WithAutoStyle(),
-					glamour.WithWordWrap(78),
-				)
+				m.state = summaryNoteToday
+			default:
+				m.noteTable, cmd = m.noteTable.Update(msg)
+				cmds = append(cmds, cmd)
+				m.listIndex = m.noteTable.Cursor()
+			}
+		case budgetView:
+			switch key {
+			case "esc":
+				m.state = listView
+			}
 
+		case summaryNoteToday, standupView, noteDetailView:
+			switch key {
+			case "esc":
+				m.state = listView
+			case "y":
+				how, err := copyToClipboard(m.viewportText)
 				if err != nil {
-					log.Fatal("unable to render glamour viewport", err)
+					m.statusMsg = "error: " + err.Error()
+					break
+				}
+				m.statusMsg = fmt.Sprintf("copied %s to clipboard (%s)", m.viewportName, how)
+			case "e":
+				if m.state == noteDetailView {
+					if note, ok := m.selectedNote(); ok {
+						m.startEditing(note)
+					}
+				}
+			case "x": // Checklist of the note
+				if m.state == noteDetailView {
+					if note, ok := m.selectedNote(); ok {
+						m.showNoteTasks(note)
+					}
+				}
+			case "o": // Attachments of the note
+				if m.state == noteDetailView {
+					if note, ok := m.selectedNote(); ok {
+						if err := m.showAttachments(note); err != nil {
+							m.statusMsg = "error: " + err.Error()
+						}
+					}
+				}
+			case "1", "2", "3", "4", "5", "6", "7", "8", "9": // Follow a link of the note
+				if m.state == noteDetailView {
+					if err := m.followLink(key); err != nil {
+						m.statusMsg = "error: " + err.Error()
+					}
+				}
+			}
+		case recurrenceView:
+			if m.isAddingRule {
+				switch key {
+				case "esc":
+					m.ruleInput.Blur()
+					m.isAddingRule = false
+				case "enter":
+					note, _ := m.selectedNote()
+					r := Recurrence{
+						Rule:      m.ruleInput.Value(),
+						Title:     note.Title,
+						Body:      note.Body,
+						TotalTime: note.TotalTime,
+						Project:   note.Project,
+						Category:  note.Category,
+						StartDate: m.currentDate,
+					}
+					store, err := m.sqlite()
+					if err != nil {
+						m.ruleErr = err
+						break
+					}
+					if err := store.SaveRecurrence(r); err != nil {
+						m.ruleErr = err
+						break
+					}
+					recurrences, err := store.GetRecurrences()
+					if err != nil {
+						m.ruleErr = err
+						break
+					}
+					m.recurrences = recurrences
+					m.recurrenceCursor = len(recurrences) - 1
+					m.ruleInput.Blur()
+					m.isAddingRule = false
 				}
+				break
+			}
 
-				notes, _ := m.store.GetNotesByDate(m.currentDate)
-				content := generateNoteSummaryContent(notes)
-				str, err := renderer.Render(content) // set str empty
+			switch key {
+			case "esc":
+				m.state = listView
+			case "down", "j":
+				if m.recurrenceCursor < len(m.recurrences)-1 {
+					m.recurrenceCursor++
+				}
+			case "up", "k":
+				if m.recurrenceCursor > 0 {
+					m.recurrenceCursor--
+				}
+			case "a": // Repeat the note selected in the list
+				if note, ok := m.selectedNote(); ok && !note.isPlaceholder() {
+					m.ruleErr = nil
+					m.ruleInput.SetValue("")
+					m.ruleInput.Focus()
+					m.isAddingRule = true
+				}
+			case "d":
+				if m.recurrenceCursor < len(m.recurrences) {
+					store, err := m.sqlite()
+					if err == nil {
+						err = store.DeleteRecurrence(m.recurrences[m.recurrenceCursor].Id)
+					}
+					if err != nil {
+						m.statusMsg = "error: " + err.Error()
+						break
+					}
+					m.recurrences = append(m.recurrences[:m.recurrenceCursor], m.recurrences[m.recurrenceCursor+1:]...)
+					if m.recurrenceCursor >= len(m.recurrences) && m.recurrenceCursor > 0 {
+						m.recurrenceCursor--
+					}
+					// placeholders of the deleted rule must go away
+					if notes, err := loadNotes(m.store, m.currentDate); err == nil {
+						m.setNotes(notes)
+					}
+				}
+			}
+
+		case dateSelectView:
+			switch key {
+			case "esc":
+				m.dateInput.Blur()
+				m.state = listView
+			case "enter":
+				date, err := parseDateInput(m.dateInput.Value())
 				if err != nil {
-					log.Fatal("unable to render content empty", err)
+					m.dateErr = err
+					break
+				}
+				note, ok := m.selectedNote()
+				if !ok {
+					m.state = listView
+					break
 				}
 
-				m.summaryNoteViewport.SetContent(str)
-				m.state = summaryNoteToday
+				m.dateInput.Blur()
+				m.state = listView
+				m.isLoading = true
+
+				action := m.dateAction
+				currentDate := m.currentDate
+				return m, tea.Batch(
+					m.spinner.Tick,
+					func() tea.Msg {
+						var err error
+						status := "moved"
+						if action == dateActionCopy {
+							err = m.store.CopyNote(note, date)
+							status = "copied"
+						} else {
+							err = m.store.MoveNote(note, date)
+						}
+						if err != nil {
+							return errMsg{err}
+						}
+						notes, err := loadNotes(m.store, currentDate)
+						if err != nil {
+							return errMsg{err}
+						}
+						return actionCompleteMsg{
+							notes:  notes,
+							status: fmt.Sprintf("%s %q to %s", status, note.Title, date.Format(dateLayout)),
+						}
+					},
+				)
 			}
-		case summaryNoteToday:
+		case templateSelectView:
 			switch key {
 			case "esc":
 				m.state = listView
+			case "down", "j":
+				if m.templateCursor < len(m.templates)-1 {
+					m.templateCursor++
+				}
+			case "up", "k":
+				if m.templateCursor > 0 {
+					m.templateCursor--
+				}
+			case "d":
+				if m.templateCursor < len(m.templates) {
+					store, err := m.sqlite()
+					if err == nil {
+						err = store.DeleteTemplate(m.templates[m.templateCursor].Id)
+					}
+					if err != nil {
+						m.statusMsg = "error: " + err.Error()
+						break
+					}
+					m.templates = append(m.templates[:m.templateCursor], m.templates[m.templateCursor+1:]...)
+					if m.templateCursor >= len(m.templates) && m.templateCursor > 0 {
+						m.templateCursor--
+					}
+				}
+			case "enter":
+				if m.templateCursor < len(m.templates) {
+					m.currTemplate = m.templates[m.templateCursor]
+					// the pickers start on the template defaults
+					m.currNote = Note{Project: m.currTemplate.Project, Category: m.currTemplate.Category}
+					m.textInput.SetValue("")
+					m.textInput.Focus()
+					m.state = titleView
+				}
 			}
+
 		case titleView:
 			switch key {
 			case "enter":
 				title := m.textInput.Value()
 				if title != "" {
 					m.currNote.Title = title
-					m.textArea.SetValue("")
+					body := ""
+					if m.currTemplate.Id != 0 {
+						body = m.currTemplate.expand(title, m.currentDate)
+					}
+					m.textArea.SetValue(body)
 					m.textArea.Focus()
 					m.textArea.CursorEnd()
 
@@ -356,7 +899,11 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				// categories > 1 will redirect if not must will force to save
 				// or set unknown or error
 				if len(m.categories) > 0 {
-					if m.isEditing {
+					if m.categoriesCursor >= len(m.categories) {
+						m.categoriesCursor = 0
+					}
+					// preselect the category of the edited note or template
+					if m.currNote.Category.Name != "" {
 						for i, category := range m.categories {
 							if category.Name == m.currNote.Category.Name { // Adjust comparison if necessary
 								m.categoriesCursor = i
@@ -434,32 +981,36 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				// force set currProject by cursor
 				m.currProject = m.projects[m.projectCursor]
 
+				// saving from the editor is the review of a draft
+				m.currNote.Draft = false
+
 				m.currCategory = m.categories[m.categoriesCursor]
 
+				// the API saves notes with the same checks
+				if err := validateNote(m.store, m.currNote.Title, m.currProject.Id, m.currCategory.Id); err != nil {
+					m.statusMsg = "error: " + err.Error()
+					break
+				}
+				m.statusMsg = ""
+
 				// Start loading spinner
 				m.isLoading = true
 
-				return m, tea.Batch(
-					m.spinner.Tick,
-					func() tea.Msg {
-						err := m.store.SaveNoteWithProject(m.currNote, m.currProject.Id, m.currCategory.Id, m.currentDate)
-						if err != nil {
-							// Handle save error (simplified for example)
-							return tea.Quit
-						}
-						newNotes, err := m.store.GetNotesByDate(m.currentDate)
-						if err != nil {
-							// Handle fetch error (simplified for example)
-							return tea.Quit
-						}
-
-						// Simulate load operation with a delay
-						time.Sleep(400 * time.Millisecond) // Simulated delay
-						return saveCompleteMsg{notes: newNotes}
-					},
-				)
+				return m, tea.Batch(m.spinner.Tick, m.saveNote(m.currNote))
 			}
 
+		case conflictView, mergeView:
+			return m.updateConflict(key)
+
+		case pomodoroView:
+			return m.updatePomodoro(key)
+
+		case attachmentsView, attachPickView:
+			return m.updateAttachments(msg)
+
+		case noteTasksView, openTasksView:
+			return m.updateTasks(key)
+
 		case timeView:
 			switch key {
 			case "q":
@@ -477,7 +1028,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 				// set cursor when edit have project data
 				// Find the index of the current project in m.projects
-				if m.isEditing {
+				if m.currNote.Project.Name != "" {
 					for i, project := range m.projects {
 						if project.Name == m.currNote.Project.Name { // Adjust comparison if necessary
 							m.projectCursor = i
@@ -489,6 +1040,13 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				//m.projectCursor = 2
 				m.currProject = m.projects[m.projectCursor]
 
+				// for the over budget warnings of the picker
+				if store, err := m.sqlite(); err == nil {
+					if statuses, err := store.GetBudgetStatuses(m.currentDate); err == nil {
+						m.budgetStatuses = statuses
+					}
+				}
+
 				m.state = projectSelectView
 				// set current project if enter
 
@@ -576,6 +1134,90 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
+func (m model) skipRecurrence(note Note) tea.Cmd {
+	currentDate := m.currentDate
+	return func() tea.Msg {
+		store, err := m.sqlite()
+		if err != nil {
+			return errMsg{err}
+		}
+		if err := store.SkipRecurrence(note.RecurrenceId, currentDate); err != nil {
+			return errMsg{err}
+		}
+		notes, err := loadNotes(m.store, currentDate)
+		if err != nil {
+			return errMsg{err}
+		}
+		return actionCompleteMsg{notes: notes, status: fmt.Sprintf("skipped %q", note.Title)}
+	}
+}
+
+// sqlite returns the SQLite store for the features the markdown backend lacks
+func (m model) sqlite() (*Store, error) {
+	if store, ok := m.store.(*Store); ok {
+		return store, nil
+	}
+	return nil, errNotSupported
+}
+
+func (m model) budgetStatus(projectId int) (BudgetStatus, bool) {
+	for _, status := range m.budgetStatuses {
+		if status.Project.Id == projectId {
+			return status, true
+		}
+	}
+	return BudgetStatus{}, false
+}
+
+func (m *model) startEditing(note Note) {
+	m.currNote = note
+	m.textArea.SetValue(m.currNote.Body)
+	m.textInputTime.SetValue(m.currNote.TotalTime)
+	m.textArea.Focus()
+	m.textArea.CursorEnd()
+
+	m.isEditing = m.currNote.Id != "" || note.isPlaceholder() // Set if editing
+
+	m.state = bodyView
+}
+
+// showMarkdown renders content into the viewport and keeps the raw text for copy
+func (m *model) showMarkdown(name, content string) error {
+	str, err := renderMarkdown(content)
+	if err != nil {
+		return err
+	}
+	m.viewportText = content
+	m.viewportName = name
+	m.summaryNoteViewport.SetContent(str)
+	m.summaryNoteViewport.GotoTop()
+	return nil
+}
+
+func noteMarkdown(note Note) string {
+	content := "# " + note.Title + "\n\n"
+	content += fmt.Sprintf("*%s / %s*", note.Project.Name, note.Category.Name)
+	if note.TotalTime != "" {
+		content += " · " + note.TotalTime
+	}
+	if !note.CreatedAt.IsZero() {
+		content += " · " + note.CreatedAt.Format(dateLayout)
+	}
+	content += "\n\n" + note.Body + "\n"
+	return content
+}
+
+func renderMarkdown(content string) (string, error) {
+	renderer, err := glamour.NewTermRenderer(
+		glamour.WithAutoStyle(),
+		glamour.WithWordWrap(78),
+	)
+	if err != nil {
+		return "", err
+	}
+	return renderer.Render(content)
+}
+
 func filterNotesByDate(notes []Note, date time.Time) []Note {
 	filtered := []Note{}
 	for _, note := range notes {
@@ -586,10 +1228,10 @@ func filterNotesByDate(notes []Note, date time.Time) []Note {
 	return filtered
 }
 
-func generateNoteSummaryContent(notes []Note) string {
+func generateNoteSummaryContent(notes []Note, pomodoros map[string]int) string {
 	content := "# Notes for Today\n\n"
-	content += "| Title           | Time       | Detail                        |\n"
-	content += "| --------------- | ---------- | --------------------------- |\n"
+	content += "| Title           | Time       | 🍅  | Detail                        |\n"
+	content += "| --------------- | ---------- | --- | --------------------------- |\n"
 
 	// Check if there are no notes
 	if len(notes) == 0 {
@@ -612,14 +1254,23 @@ func generateNoteSummaryContent(notes []Note) string {
 		}
 
 		// Format the content with note data
-		content += fmt.Sprintf("| %s | %s | [%s] - %s  |\n",
+		content += fmt.Sprintf("| %s | %s | %d | [%s] - %s  |\n",
 			note.Title,
 			note.TotalTime,
+			pomodoros[note.Id],
 			projectName,
 			body,
 		)
 	}
 
+	total := 0
+	for _, count := range pomodoros {
+		total += count
+	}
+	if total > 0 {
+		content += fmt.Sprintf("\n%d pomodoros completed\n", total)
+	}
+
 	return content
 }
 
@@ -0,0 +1,341 @@
+package tui
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	defaultPomodoroWork        = "25m"
+	defaultPomodoroBreak       = "5m"
+	defaultPomodoroLongBreak   = "15m"
+	defaultPomodoroLongEvery   = 4
+	pomodoroTickInterval       = time.Second
+	pomodoroPhaseWork          = "work"
+	pomodoroPhaseBreak         = "break"
+	pomodoroPhaseBreakOver     = "break over"
+	pomodoroInterruptedMinimum = time.Minute
+)
+
+// PomodoroSettings are the interval lengths of pomodoro mode
+type PomodoroSettings struct {
+	Work      time.Duration
+	Break     time.Duration
+	LongBreak time.Duration
+	LongEvery int // every that many pomodoros the break is a long one
+}
+
+// Pomodoro is one work interval spent on a note
+type Pomodoro struct {
+	Id          int64
+	NoteId      string
+	StartedAt   time.Time
+	Planned     time.Duration
+	Focused     time.Duration
+	Completed   bool
+	Interrupted bool
+}
+
+func (s *Store) initPomodoros() error {
+	createTablePomodorosStmt := `
+        CREATE TABLE IF NOT EXISTS Pomodoros (
+            Id INTEGER PRIMARY KEY AUTOINCREMENT,
+            NoteId TEXT NOT NULL,
+            StartedAt TIMESTAMP NOT NULL,
+            EndedAt TIMESTAMP,
+            Planned INTEGER NOT NULL,
+            Focused INTEGER NOT NULL DEFAULT 0,
+            Completed INTEGER NOT NULL DEFAULT 0,
+            Interrupted INTEGER NOT NULL DEFAULT 0
+        );`
+
+	_, err := s.conn.Exec(createTablePomodorosStmt)
+	return err
+}
+
+// StartPomodoro records a running work interval on the note. Intervals left
+// running by a TUI that quit without finishing them count as interrupted.
+func (s *Store) StartPomodoro(noteId string, planned time.Duration) (Pomodoro, error) {
+	now := time.Now().UTC()
+	if _, err := s.conn.Exec("UPDATE Pomodoros SET EndedAt = StartedAt, Interrupted = 1 WHERE EndedAt IS NULL;"); err != nil {
+		return Pomodoro{}, err
+	}
+
+	result, err := s.conn.Exec("INSERT INTO Pomodoros (NoteId, StartedAt, Planned) VALUES (?, ?, ?);",
+		noteId, now, int64(planned/time.Second))
+	if err != nil {
+		return Pomodoro{}, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return Pomodoro{}, err
+	}
+	return Pomodoro{Id: id, NoteId: noteId, StartedAt: now, Planned: planned}, nil
+}
+
+// FinishPomodoro ends the interval and adds the focused time to the logged
+// time of its note, both or neither are saved. The time of an invoiced note
+// is left as it was billed.
+func (s *Store) FinishPomodoro(p Pomodoro) error {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	_, err = tx.Exec("UPDATE Pomodoros SET EndedAt = ?, Focused = ?, Completed = ?, Interrupted = ? WHERE Id = ?;",
+		now, int64(p.Focused/time.Second), p.Completed, p.Interrupted, p.Id)
+	if err != nil {
+		return err
+	}
+
+	if p.Focused > 0 {
+		var totalTime string
+		var invoiceId int
+		err := tx.QueryRow("SELECT TotalTime, COALESCE(InvoiceId, 0) FROM Notes WHERE Id = ?;", p.NoteId).Scan(&totalTime, &invoiceId)
+		if err == sql.ErrNoRows {
+			return tx.Commit() // the note was deleted meanwhile, keep the record
+		} else if err != nil {
+			return err
+		}
+		if invoiceId != 0 {
+			return tx.Commit() // invoiced meanwhile, keep the record
+		}
+
+		logged := formatDuration(parseTotalTime(totalTime) + p.Focused)
+		if _, err := tx.Exec("UPDATE Notes SET TotalTime = ?, UpdatedAt = ? WHERE Id = ?;", logged, now, p.NoteId); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// PomodoroCounts returns the completed pomodoros per note id for the notes of date
+func (s *Store) PomodoroCounts(date time.Time) (map[string]int, error) {
+	rows, err := s.conn.Query(`
+        SELECT p.NoteId, count(*)
+        FROM Pomodoros p
+        INNER JOIN Notes n ON n.Id = p.NoteId
+        WHERE p.Completed = 1 AND date(n.CreatedAt) = date(?)
+        GROUP BY p.NoteId;`, date.UTC().Format(dateLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var noteId string
+		var count int
+		if err := rows.Scan(&noteId, &count); err != nil {
+			return nil, err
+		}
+		counts[noteId] = count
+	}
+	return counts, rows.Err()
+}
+
+// pomodoroState is the running pomodoro mode of the TUI
+type pomodoroState struct {
+	note      Note
+	current   Pomodoro
+	phase     string
+	endsAt    time.Time
+	completed int // pomodoros finished in this run
+	session   int // tells the ticks of this run from those of an earlier one
+}
+
+type pomodoroTickMsg struct {
+	session int
+}
+
+// pomodoroSavedMsg reports a finished interval, notes are reloaded because
+// the logged time changed
+type pomodoroSavedMsg struct {
+	notes  []Note
+	status string
+}
+
+func (m model) pomodoroTick() tea.Cmd {
+	session := m.pomodoro.session
+	return tea.Tick(pomodoroTickInterval, func(time.Time) tea.Msg {
+		return pomodoroTickMsg{session: session}
+	})
+}
+
+// startPomodoro begins a work interval on the note
+func (m model) startPomodoro(note Note) (model, tea.Cmd) {
+	store, err := m.sqlite()
+	if err != nil {
+		m.statusMsg = "error: " + err.Error()
+		return m, nil
+	}
+
+	settings := m.config.PomodoroSettings()
+	p, err := store.StartPomodoro(note.Id, settings.Work)
+	if err != nil {
+		m.statusMsg = "error: " + err.Error()
+		return m, nil
+	}
+
+	m.pomodoro.note = note
+	m.pomodoro.current = p
+	m.pomodoro.phase = pomodoroPhaseWork
+	m.pomodoro.endsAt = p.StartedAt.Add(settings.Work)
+	m.pomodoro.session++
+	m.state = pomodoroView
+	return m, m.pomodoroTick()
+}
+
+// finishPomodoro saves the running work interval, interrupted when it ends early
+func (m model) finishPomodoro(completed bool) tea.Cmd {
+	p := m.pomodoro.current
+	p.Completed = completed
+	p.Interrupted = !completed
+	p.Focused = time.Since(p.StartedAt).Round(time.Second)
+	if p.Focused > p.Planned {
+		p.Focused = p.Planned
+	}
+	if !completed && p.Focused < pomodoroInterruptedMinimum {
+		p.Focused = 0 // started by mistake, nothing to log
+	}
+
+	currentDate := m.currentDate
+	title := m.pomodoro.note.Title
+	return func() tea.Msg {
+		store, err := m.sqlite()
+		if err != nil {
+			return errMsg{err}
+		}
+		if err := store.FinishPomodoro(p); err != nil {
+			return errMsg{err}
+		}
+		notes, err := loadNotes(m.store, currentDate)
+		if err != nil {
+			return errMsg{err}
+		}
+		status := fmt.Sprintf("logged %s on %q", formatDuration(p.Focused), title)
+		if !completed && p.Focused == 0 {
+			status = "pomodoro interrupted, nothing logged"
+		} else if !completed {
+			status = "pomodoro interrupted, " + status
+		}
+		return pomodoroSavedMsg{notes: notes, status: status}
+	}
+}
+
+func (m model) updatePomodoroTick(msg pomodoroTickMsg) (model, tea.Cmd) {
+	if m.state != pomodoroView || msg.session != m.pomodoro.session {
+		return m, nil // a tick of a stopped run
+	}
+	if time.Now().Before(m.pomodoro.endsAt) {
+		return m, m.pomodoroTick()
+	}
+
+	settings := m.config.PomodoroSettings()
+	switch m.pomodoro.phase {
+	case pomodoroPhaseWork:
+		m.pomodoro.completed++
+		pause := settings.Break
+		if settings.LongEvery > 0 && m.pomodoro.completed%settings.LongEvery == 0 {
+			pause = settings.LongBreak
+		}
+		m.pomodoro.phase = pomodoroPhaseBreak
+		m.pomodoro.endsAt = time.Now().Add(pause)
+		return m, tea.Batch(m.finishPomodoro(true), m.pomodoroTick())
+	case pomodoroPhaseBreak:
+		m.pomodoro.phase = pomodoroPhaseBreakOver
+	}
+	return m, nil
+}
+
+func (m model) updatePomodoro(key string) (model, tea.Cmd) {
+	switch key {
+	case "esc", "q": // Stop, a running work interval counts as interrupted
+		m.state = listView
+		if m.pomodoro.phase == pomodoroPhaseWork {
+			return m, m.finishPomodoro(false)
+		}
+	case "enter": // Next pomodoro, also cuts a break short
+		if m.pomodoro.phase != pomodoroPhaseWork {
+			return m.startPomodoro(m.pomodoro.note)
+		}
+	}
+	return m, nil
+}
+
+var (
+	pomodoroWorkStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Bold(true)
+	pomodoroBreakStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("42")).Bold(true)
+)
+
+// bigDigits draws the countdown five lines high
+var bigDigits = map[rune][]string{
+	'0': {"███", "█ █", "█ █", "█ █", "███"},
+	'1': {" █ ", "██ ", " █ ", " █ ", "███"},
+	'2': {"███", "  █", "███", "█  ", "███"},
+	'3': {"███", "  █", "███", "  █", "███"},
+	'4': {"█ █", "█ █", "███", "  █", "  █"},
+	'5': {"███", "█  ", "███", "  █", "███"},
+	'6': {"███", "█  ", "███", "█ █", "███"},
+	'7': {"███", "  █", "  █", "  █", "  █"},
+	'8': {"███", "█ █", "███", "█ █", "███"},
+	'9': {"███", "█ █", "███", "  █", "███"},
+	':': {"   ", " █ ", "   ", " █ ", "   "},
+}
+
+func renderBigClock(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	d = d.Round(time.Second)
+	clock := fmt.Sprintf("%02d:%02d", int(d/time.Minute), int(d%time.Minute/time.Second))
+
+	lines := make([]string, 5)
+	for _, r := range clock {
+		for i, row := range bigDigits[r] {
+			lines[i] += row + " "
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m model) pomodoroView() string {
+	p := m.pomodoro
+	remaining := time.Until(p.endsAt)
+
+	var title, help string
+	style := pomodoroBreakStyle
+	switch p.phase {
+	case pomodoroPhaseWork:
+		style = pomodoroWorkStyle
+		title = "Focus on " + p.note.Title
+		help = "esc - stop (counts as interrupted)"
+	case pomodoroPhaseBreak:
+		title = "Break"
+		help = "enter - next pomodoro now, esc - stop"
+	default:
+		title = "Break is over"
+		remaining = 0
+		help = "enter - next pomodoro, esc - stop"
+	}
+
+	s := style.Render(title) + "\n\n" +
+		style.Render(renderBigClock(remaining)) + "\n\n" +
+		faintStyle.Render(fmt.Sprintf("%d pomodoros done, %s logged on this note", p.completed, p.note.TotalTime)) + "\n\n" +
+		faintStyle.Render(help)
+	if m.statusMsg != "" {
+		s += "\n\n" + statusStyle.Render(m.statusMsg)
+	}
+
+	if m.width == 0 || m.height == 0 {
+		return s
+	}
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, s)
+}
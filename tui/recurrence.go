@@ -0,0 +1,361 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurrence is a note that repeats following an RRULE-like rule, e.g.
+// "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO" for sprint planning every other Monday
+type Recurrence struct {
+	Id        int
+	Rule      string
+	Title     string
+	Body      string
+	TotalTime string
+	Project   Project
+	Category  Category
+	StartDate time.Time
+}
+
+type rule struct {
+	freq       string
+	interval   int
+	byDay      []time.Weekday
+	byMonthDay []int
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// ruleShortcuts are accepted in place of a full rule
+var ruleShortcuts = map[string]string{
+	"DAILY":    "FREQ=DAILY",
+	"WEEKDAYS": "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR",
+	"WEEKLY":   "FREQ=WEEKLY",
+	"MONTHLY":  "FREQ=MONTHLY",
+}
+
+func parseRule(s string) (rule, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if full, ok := ruleShortcuts[s]; ok {
+		s = full
+	}
+
+	r := rule{interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return rule{}, fmt.Errorf("invalid rule part %q", part)
+		}
+
+		switch name {
+		case "FREQ":
+			if value != "DAILY" && value != "WEEKLY" && value != "MONTHLY" {
+				return rule{}, fmt.Errorf("unsupported FREQ %q", value)
+			}
+			r.freq = value
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return rule{}, fmt.Errorf("invalid INTERVAL %q", value)
+			}
+			r.interval = n
+		case "BYDAY":
+			for _, code := range strings.Split(value, ",") {
+				day, ok := weekdayCodes[code]
+				if !ok {
+					return rule{}, fmt.Errorf("invalid BYDAY %q", code)
+				}
+				r.byDay = append(r.byDay, day)
+			}
+		case "BYMONTHDAY":
+			for _, v := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 1 || n > 31 {
+					return rule{}, fmt.Errorf("invalid BYMONTHDAY %q", v)
+				}
+				r.byMonthDay = append(r.byMonthDay, n)
+			}
+		default:
+			return rule{}, fmt.Errorf("unsupported rule part %q", name)
+		}
+	}
+
+	if r.freq == "" {
+		return rule{}, fmt.Errorf("rule needs a FREQ")
+	}
+	return r, nil
+}
+
+// occursOn reports if the rule started at start has an occurrence on date
+func (r rule) occursOn(start, date time.Time) bool {
+	start = start.UTC().Truncate(24 * time.Hour)
+	date = date.UTC().Truncate(24 * time.Hour)
+	if date.Before(start) {
+		return false
+	}
+
+	switch r.freq {
+	case "DAILY":
+		days := int(date.Sub(start).Hours() / 24)
+		return days%r.interval == 0
+
+	case "WEEKLY":
+		byDay := r.byDay
+		if len(byDay) == 0 {
+			byDay = []time.Weekday{start.Weekday()}
+		}
+		if !containsWeekday(byDay, date.Weekday()) {
+			return false
+		}
+		weeks := int(weekStart(date).Sub(weekStart(start)).Hours() / (24 * 7))
+		return weeks%r.interval == 0
+
+	case "MONTHLY":
+		byMonthDay := r.byMonthDay
+		if len(byMonthDay) == 0 {
+			byMonthDay = []int{start.Day()}
+		}
+		found := false
+		for _, d := range byMonthDay {
+			if d == date.Day() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+		months := (date.Year()-start.Year())*12 + int(date.Month()-start.Month())
+		return months%r.interval == 0
+	}
+
+	return false
+}
+
+func containsWeekday(days []time.Weekday, day time.Weekday) bool {
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// weekStart returns the monday of the week of date
+func weekStart(date time.Time) time.Time {
+	offset := (int(date.Weekday()) + 6) % 7
+	return date.AddDate(0, 0, -offset)
+}
+
+// placeholder builds the note proposed for the rule, it has no Id until accepted
+func (r Recurrence) placeholder() Note {
+	return Note{
+		Title:        r.Title,
+		Body:         r.Body,
+		TotalTime:    r.TotalTime,
+		Project:      r.Project,
+		Category:     r.Category,
+		RecurrenceId: r.Id,
+	}
+}
+
+func (n Note) isPlaceholder() bool {
+	return n.Id == "" && n.RecurrenceId != 0
+}
+
+func (s *Store) initRecurrences() error {
+	createTableRecurrencesStmt := `
+        CREATE TABLE IF NOT EXISTS Recurrences (
+            Id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+            Rule TEXT NOT NULL,
+            Title TEXT NOT NULL,
+            Body TEXT NOT NULL,
+            TotalTime TEXT,
+            ProjectId INTEGER NOT NULL,
+            CategoryId INTEGER,
+            StartDate TIMESTAMP NOT NULL,
+            CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+            FOREIGN KEY (ProjectId) REFERENCES Projects(Id),
+            FOREIGN KEY (CategoryId) REFERENCES Categories(Id)
+        );`
+
+	createTableRecurrenceSkipsStmt := `
+        CREATE TABLE IF NOT EXISTS RecurrenceSkips (
+            RecurrenceId INTEGER NOT NULL,
+            Date TEXT NOT NULL,
+            PRIMARY KEY (RecurrenceId, Date),
+            FOREIGN KEY (RecurrenceId) REFERENCES Recurrences(Id)
+        );`
+
+	if _, err := s.conn.Exec(createTableRecurrencesStmt); err != nil {
+		return err
+	}
+
+	if _, err := s.conn.Exec(createTableRecurrenceSkipsStmt); err != nil {
+		return err
+	}
+
+	// Notes created from a rule remember it, so the rule is not proposed twice
+	return s.addColumn("Notes", "RecurrenceId", "INTEGER")
+}
+
+func (s *Store) SaveRecurrence(r Recurrence) error {
+	if _, err := parseRule(r.Rule); err != nil {
+		return err
+	}
+
+	if s.Locked() {
+		return ErrLocked
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the id is part of the encryption, the row is made first
+	insertQuery := `
+    INSERT INTO Recurrences (Rule, Title, Body, TotalTime, ProjectId, CategoryId, StartDate)
+    VALUES (?, '', '', ?, ?, ?, ?);`
+
+	result, err := tx.Exec(insertQuery, r.Rule, r.TotalTime,
+		r.Project.Id, r.Category.Id, r.StartDate.UTC().Truncate(24*time.Hour))
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	// titles and bodies are copied from notes, they are encrypted like them
+	title, err := s.seal(r.Title, rowId("recurrence", int(id)), "Title")
+	if err != nil {
+		return err
+	}
+	body, err := s.seal(r.Body, rowId("recurrence", int(id)), "Body")
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE Recurrences SET Title = ?, Body = ? WHERE Id = ?;", title, body, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) GetRecurrences() ([]Recurrence, error) {
+	query := `
+        SELECT
+            r.Id, r.Rule, r.Title, r.Body, r.TotalTime, r.StartDate,
+            p.Id, p.Name, p.Description,
+            c.Id, c.Name
+        FROM Recurrences r
+        INNER JOIN Projects p ON r.ProjectId = p.Id
+        LEFT JOIN Categories c ON r.CategoryId = c.Id
+        ORDER BY r.Id;
+    `
+
+	rows, err := s.conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recurrences []Recurrence
+	for rows.Next() {
+		var r Recurrence
+		if err := rows.Scan(
+			&r.Id, &r.Rule, &r.Title, &r.Body, &r.TotalTime, &r.StartDate,
+			&r.Project.Id, &r.Project.Name, &r.Project.Description,
+			&r.Category.Id, &r.Category.Name,
+		); err != nil {
+			return nil, err
+		}
+		if r.Title, err = s.open(r.Title, rowId("recurrence", r.Id), "Title"); err != nil {
+			return nil, err
+		}
+		if r.Body, err = s.open(r.Body, rowId("recurrence", r.Id), "Body"); err != nil {
+			return nil, err
+		}
+		recurrences = append(recurrences, r)
+	}
+	return recurrences, rows.Err()
+}
+
+func (s *Store) DeleteRecurrence(id int) error {
+	if _, err := s.conn.Exec("DELETE FROM RecurrenceSkips WHERE RecurrenceId = ?", id); err != nil {
+		return err
+	}
+	_, err := s.conn.Exec("DELETE FROM Recurrences WHERE Id = ?", id)
+	return err
+}
+
+// SkipRecurrence stops proposing the rule on date
+func (s *Store) SkipRecurrence(id int, date time.Time) error {
+	query := `INSERT OR IGNORE INTO RecurrenceSkips (RecurrenceId, Date) VALUES (?, ?);`
+	_, err := s.conn.Exec(query, id, date.UTC().Format(dateLayout))
+	return err
+}
+
+// GetPendingRecurrences returns a placeholder note for every rule occurring
+// on date that has neither a note nor a skip yet
+func (s *Store) GetPendingRecurrences(date time.Time) ([]Note, error) {
+	recurrences, err := s.GetRecurrences()
+	if err != nil {
+		return nil, err
+	}
+
+	day := date.UTC().Format(dateLayout)
+
+	var notes []Note
+	for _, r := range recurrences {
+		parsed, err := parseRule(r.Rule)
+		if err != nil || !parsed.occursOn(r.StartDate, date) {
+			continue
+		}
+
+		var count int
+		query := `
+            SELECT
+                (SELECT COUNT(*) FROM Notes WHERE RecurrenceId = ? AND date(CreatedAt) = date(?)) +
+                (SELECT COUNT(*) FROM RecurrenceSkips WHERE RecurrenceId = ? AND Date = ?);`
+		if err := s.conn.QueryRow(query, r.Id, day, r.Id, day).Scan(&count); err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			notes = append(notes, r.placeholder())
+		}
+	}
+	return notes, nil
+}
+
+// loadNotes returns the notes of date followed by the pending recurring ones,
+// only the SQLite backend has recurrences
+func loadNotes(storage Storage, date time.Time) ([]Note, error) {
+	notes, err := storage.GetNotesByDate(date)
+	if err != nil {
+		return nil, err
+	}
+
+	store, ok := storage.(*Store)
+	if !ok {
+		return notes, nil
+	}
+	pending, err := store.GetPendingRecurrences(date)
+	if err != nil {
+		return nil, err
+	}
+	return append(notes, pending...), nil
+}
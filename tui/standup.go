@@ -0,0 +1,167 @@
+package tui
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// StandupGroup holds the notes of one project for a standup day
+type StandupGroup struct {
+	Project string
+	Notes   []Note
+	Total   time.Duration
+}
+
+// Standup is the data given to the standup template
+type Standup struct {
+	Yesterday time.Time
+	Today     time.Time
+	Done      []StandupGroup // notes of the previous working day
+	Planned   []StandupGroup // notes of today
+	Blockers  []string       // body lines starting with "blocker:" or "blocked:"
+}
+
+const defaultStandupTemplate = `# Standup {{.Today.Format "Mon 02 Jan 2006"}}
+
+## Yesterday ({{.Yesterday.Format "Mon 02 Jan"}})
+{{range .Done}}
+**{{.Project}}**{{if .Total}} ({{duration .Total}}){{end}}
+{{range .Notes}}
+- {{.Title}}{{if .TotalTime}} ({{.TotalTime}}){{end}}
+{{- end}}
+{{else}}
+- Nothing logged
+{{end}}
+## Today
+{{range .Planned}}
+**{{.Project}}**{{if .Total}} ({{duration .Total}}){{end}}
+{{range .Notes}}
+- {{.Title}}{{if .TotalTime}} ({{.TotalTime}}){{end}}
+{{- end}}
+{{else}}
+- Nothing logged yet
+{{end}}
+## Blockers
+{{range .Blockers}}
+- {{.}}
+{{- else}}
+- None
+{{- end}}
+`
+
+// previousWorkingDay returns the day before date, skipping weekends
+func previousWorkingDay(date time.Time) time.Time {
+	day := date.AddDate(0, 0, -1)
+	for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+func groupByProject(notes []Note) []StandupGroup {
+	groups := map[string]*StandupGroup{}
+	var names []string
+
+	for _, note := range notes {
+		name := note.Project.Name
+		group, ok := groups[name]
+		if !ok {
+			group = &StandupGroup{Project: name}
+			groups[name] = group
+			names = append(names, name)
+		}
+		group.Notes = append(group.Notes, note)
+		group.Total += parseTotalTime(note.TotalTime)
+	}
+
+	sort.Strings(names)
+	result := make([]StandupGroup, 0, len(names))
+	for _, name := range names {
+		result = append(result, *groups[name])
+	}
+	return result
+}
+
+func findBlockers(notes []Note) []string {
+	var blockers []string
+	for _, note := range notes {
+		for _, line := range strings.Split(note.Body, "\n") {
+			line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-*"))
+			lower := strings.ToLower(line)
+			for _, prefix := range []string{"blocker:", "blocked:"} {
+				if strings.HasPrefix(lower, prefix) {
+					blockers = append(blockers, strings.TrimSpace(line[len(prefix):]))
+				}
+			}
+		}
+	}
+	return blockers
+}
+
+func withoutDrafts(notes []Note) []Note {
+	kept := notes[:0]
+	for _, note := range notes {
+		if !note.Draft {
+			kept = append(kept, note)
+		}
+	}
+	return kept
+}
+
+func BuildStandup(store Storage, date time.Time) (Standup, error) {
+	yesterday := previousWorkingDay(date)
+
+	done, err := store.GetNotesByDate(yesterday)
+	if err != nil {
+		return Standup{}, err
+	}
+	planned, err := store.GetNotesByDate(date)
+	if err != nil {
+		return Standup{}, err
+	}
+	done, planned = withoutDrafts(done), withoutDrafts(planned)
+	sortNotes(done, sortByCreated)
+	sortNotes(planned, sortByCreated)
+
+	return Standup{
+		Yesterday: yesterday,
+		Today:     date,
+		Done:      groupByProject(done),
+		Planned:   groupByProject(planned),
+		Blockers:  findBlockers(append(done, planned...)),
+	}, nil
+}
+
+// StandupReport renders the standup of date as markdown. templatePath may
+// point to a text/template file, empty uses the default template.
+func StandupReport(store Storage, date time.Time, templatePath string) (string, error) {
+	text := defaultStandupTemplate
+	if templatePath != "" {
+		data, err := os.ReadFile(templatePath)
+		if err != nil {
+			return "", err
+		}
+		text = string(data)
+	}
+
+	tmpl, err := template.New("standup").
+		Funcs(template.FuncMap{"duration": formatDuration}).
+		Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	standup, err := BuildStandup(store, date)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, standup); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
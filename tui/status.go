@@ -0,0 +1,190 @@
+package tui
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// DefaultStatusFormat is printed by notes status without -format or status_format
+const DefaultStatusFormat = "{today_total} / {target} {running_timer}"
+
+// DefaultStatusMaxAge is how long notes status trusts its cache
+const DefaultStatusMaxAge = 30 * time.Second
+
+var statusPlaceholder = regexp.MustCompile(`\{(\w+)\}`)
+
+// RenderStatus fills the {placeholders} of format, unknown ones are kept
+func RenderStatus(format string, fields map[string]string) string {
+	return statusPlaceholder.ReplaceAllStringFunc(format, func(match string) string {
+		if value, ok := fields[match[1:len(match)-1]]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+// statusFields computes the placeholders from the notes of day
+func statusFields(notes []Note, config Config, day time.Time) map[string]string {
+	logged := loggedTime(notes)
+	target := config.TargetFor(day)
+
+	drafts := 0
+	for _, note := range notes {
+		if note.Draft {
+			drafts++
+		}
+	}
+
+	remaining := target - logged
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return map[string]string{
+		"date":          day.Format(dateLayout),
+		"today_total":   formatDuration(logged),
+		"target":        formatDuration(target),
+		"remaining":     formatDuration(remaining),
+		"notes":         fmt.Sprint(len(notes) - drafts),
+		"drafts":        fmt.Sprint(drafts),
+		"running_timer": "",
+	}
+}
+
+// runningTimer shows the time left of a running pomodoro, empty without one
+func runningTimer(endsAt time.Time) string {
+	left := time.Until(endsAt).Round(time.Second)
+	if endsAt.IsZero() || left <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("🍅 %02d:%02d", int(left/time.Minute), int(left%time.Minute/time.Second))
+}
+
+// statusCache is kept in the user cache folder between calls of notes status
+type statusCache struct {
+	Key       string            `json:"key"`
+	At        time.Time         `json:"at"`
+	Fields    map[string]string `json:"fields"`
+	TimerEnds time.Time         `json:"timer_ends"` // the countdown moves while cached
+}
+
+// StatusFields returns the values of the status placeholders for today. The
+// SQLite database is opened read only and never initialised, and the result
+// is cached for up to maxAge or until the database changes.
+func StatusFields(storage Storage, config Config, maxAge time.Duration) (map[string]string, error) {
+	day := today()
+
+	store, ok := storage.(*Store)
+	if !ok {
+		if err := storage.Init(); err != nil {
+			return nil, err
+		}
+		notes, err := storage.GetNotesByDate(day)
+		if err != nil {
+			return nil, err
+		}
+		return statusFields(notes, config, day), nil
+	}
+
+	key, err := statusKey(store.Path, day)
+	if err != nil {
+		return nil, err
+	}
+	cachePath := statusCachePath(store.Path)
+
+	var cache statusCache
+	if data, err := os.ReadFile(cachePath); err == nil && json.Unmarshal(data, &cache) == nil {
+		if cache.Key == key && time.Since(cache.At) < maxAge {
+			cache.Fields["running_timer"] = runningTimer(cache.TimerEnds)
+			return cache.Fields, nil
+		}
+	}
+
+	notes, timerEnds, err := readStatusNotes(store.Path, day)
+	if err != nil {
+		return nil, err
+	}
+	fields := statusFields(notes, config, day)
+	fields["running_timer"] = runningTimer(timerEnds)
+
+	// a cache that cannot be written only makes the next call slower
+	cache = statusCache{Key: key, At: time.Now(), Fields: fields, TimerEnds: timerEnds}
+	if data, err := json.Marshal(cache); err == nil && os.MkdirAll(filepath.Dir(cachePath), 0o755) == nil {
+		writeFileAtomic(cachePath, data)
+	}
+	return fields, nil
+}
+
+// statusKey changes with the day and with every write to the database,
+// which touches the database file or its write-ahead log
+func statusKey(path string, day time.Time) (string, error) {
+	key := day.Format(dateLayout)
+	for _, suffix := range []string{"", "-wal"} {
+		info, err := os.Stat(path + suffix)
+		if os.IsNotExist(err) && suffix != "" {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		key += fmt.Sprintf("|%d|%d", info.Size(), info.ModTime().UnixNano())
+	}
+	return key, nil
+}
+
+func statusCachePath(dbPath string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	abs, err := filepath.Abs(dbPath)
+	if err != nil {
+		abs = dbPath
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(dir, "notes-bubbletea-cli", "status-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+// readStatusNotes reads only the unencrypted columns status needs, so no
+// passphrase is asked for, and when the running pomodoro ends
+func readStatusNotes(path string, day time.Time) ([]Note, time.Time, error) {
+	db, err := sql.Open("sqlite3", sqliteDSN(path, "mode=ro&_busy_timeout=1000"))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT TotalTime, Draft FROM Notes WHERE date(CreatedAt) = date(?);", day.UTC().Format(dateLayout))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer rows.Close()
+
+	var notes []Note
+	for rows.Next() {
+		var note Note
+		if err := rows.Scan(&note.TotalTime, &note.Draft); err != nil {
+			return nil, time.Time{}, err
+		}
+		notes = append(notes, note)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	// databases the TUI has not opened since pomodoros came have no table
+	var startedAt time.Time
+	var planned int64
+	err = db.QueryRow("SELECT StartedAt, Planned FROM Pomodoros WHERE EndedAt IS NULL ORDER BY Id DESC LIMIT 1;").
+		Scan(&startedAt, &planned)
+	if err != nil {
+		return notes, time.Time{}, nil
+	}
+	return notes, startedAt.Add(time.Duration(planned) * time.Second), nil
+}
@@ -0,0 +1,156 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Storage is what the TUI needs from a backend. Store keeps everything in
+// SQLite, FileStore keeps one Markdown file per note.
+type Storage interface {
+	Init() error
+
+	GetNotes() ([]Note, error)
+	GetNotesByDate(currentDate time.Time) ([]Note, error)
+	GetNotesByDateRange(from, to time.Time) ([]Note, error)
+	GetNotesByProject(projectId int) ([]Note, error)
+	GetNoteById(noteId string) (Note, error)
+	SaveNoteWithProject(note Note, projectId, category int, currentdate time.Time) error
+	DeleteNote(noteId string) error
+	MoveNote(note Note, date time.Time) error
+	CopyNote(note Note, date time.Time) error
+	CopyNotesByDate(from, to time.Time) (int, error)
+	UpdateNoteCategory(noteId string, categoryId int) error
+	SetNoteDraft(noteId string, draft bool) error
+	SetNoteBillable(noteId string, billable bool) error
+
+	SaveProject(project Project) error
+	GetProjects() ([]Project, error)
+	GetProjectById(projectId int) (Project, error)
+	GetProjectByName(name string) (Project, error)
+	SaveCategory(name string) (Category, error)
+	GetCategoriesByProject(projectId int) ([]Category, error)
+	AssignCategoriesToProject(projectId int, categoryIds []int) error
+}
+
+const (
+	BackendSQLite   = "sqlite"
+	BackendMarkdown = "markdown"
+)
+
+// errNotSupported is returned for the features only the SQLite backend has
+var errNotSupported = errors.New("not supported by the markdown backend")
+
+// invalidNoteError is a note the editor would not let through
+type invalidNoteError string
+
+func (e invalidNoteError) Error() string {
+	return string(e)
+}
+
+// validateNote checks a note before it is saved from the editor or the API:
+// it needs a title, an existing project and a category of that project
+func validateNote(store Storage, title string, projectId, categoryId int) error {
+	if strings.TrimSpace(title) == "" {
+		return invalidNoteError("title must not be empty")
+	}
+
+	project, err := store.GetProjectById(projectId)
+	if err != nil {
+		return err
+	}
+	if project.Id == 0 {
+		return invalidNoteError(fmt.Sprintf("unknown project_id %d", projectId))
+	}
+
+	categories, err := store.GetCategoriesByProject(projectId)
+	if err != nil {
+		return err
+	}
+	for _, category := range categories {
+		if category.Id == categoryId {
+			return nil
+		}
+	}
+	if categoryId == 0 {
+		return invalidNoteError(fmt.Sprintf("category_id is needed, project %q has %d categories", project.Name, len(categories)))
+	}
+	return invalidNoteError(fmt.Sprintf("category_id %d is not a category of project %q", categoryId, project.Name))
+}
+
+// ConflictError is returned when a note is saved that was changed by someone
+// else since it was loaded
+type ConflictError struct {
+	Mine   Note // the note that was not saved
+	Theirs Note // the note as it is stored now
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("note %q was changed at %s since it was loaded",
+		e.Theirs.Title, e.Theirs.UpdatedAt.Local().Format("15:04:05"))
+}
+
+// Convert copies the projects, categories and notes of src into dst.
+// Ids and dates of notes are kept, invoices and SQLite only data are not.
+func Convert(src, dst Storage) (int, error) {
+	projects, err := src.GetProjects()
+	if err != nil {
+		return 0, err
+	}
+
+	projectIds := map[int]int{}  // src project id -> dst project id
+	categoryIds := map[int]int{} // src category id -> dst category id
+	for _, project := range projects {
+		if err := dst.SaveProject(project); err != nil {
+			return 0, err
+		}
+		saved, err := dst.GetProjectByName(project.Name)
+		if err != nil {
+			return 0, err
+		}
+		projectIds[project.Id] = saved.Id
+
+		categories, err := src.GetCategoriesByProject(project.Id)
+		if err != nil {
+			return 0, err
+		}
+		var ids []int
+		for _, category := range categories {
+			savedCategory, err := dst.SaveCategory(category.Name)
+			if err != nil {
+				return 0, err
+			}
+			categoryIds[category.Id] = savedCategory.Id
+			ids = append(ids, savedCategory.Id)
+		}
+		if err := dst.AssignCategoriesToProject(saved.Id, ids); err != nil {
+			return 0, err
+		}
+	}
+
+	notes, err := src.GetNotes()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, note := range notes {
+		note.RecurrenceId = 0        // rules stay in the source
+		note.UpdatedAt = time.Time{} // overwrite notes copied before
+		if err := dst.SaveNoteWithProject(note, projectIds[note.Project.Id], categoryIds[note.Category.Id], note.CreatedAt); err != nil {
+			return 0, err
+		}
+		if err := dst.SetNoteBillable(note.Id, note.Billable); err != nil {
+			return 0, err
+		}
+	}
+
+	// links to notes copied after the linking note are resolved now
+	if store, ok := dst.(*Store); ok {
+		if err := store.rebuildLinks(); err != nil {
+			return 0, err
+		}
+	}
+	return len(notes), nil
+}
@@ -1,7 +1,10 @@
 package tui
 
 import (
+	"crypto/cipher"
 	"database/sql"
+	"fmt"
+	"net/url"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,6 +20,13 @@ type Note struct {
 	Category  Category
 	CreatedAt time.Time
 	UpdatedAt time.Time
+
+	RecurrenceId int // rule the note was created from, 0 if none
+
+	Billable  bool
+	InvoiceId int // invoice the note was billed on, 0 if none
+
+	Draft bool // imported and not reviewed yet
 }
 
 type Project struct {
@@ -33,13 +43,59 @@ type Category struct {
 	Name string
 }
 
+// Mock data inserted by every backend on first start
+var (
+	mockProjects = []Project{
+		{Name: "Work", Description: "Work-related tasks"},
+		{Name: "Personal", Description: "Personal notes and ideas"},
+		{Name: "Hobbies", Description: "Notes for hobbies and interests"},
+		{Name: "General", Description: "Notes for general idea"},
+	}
+
+	mockCategories = []Category{
+		{Name: "Urgent"},
+		{Name: "Important"},
+		{Name: "Optional"},
+	}
+
+	mockAssignments = map[string][]string{
+		"Work":     {"Urgent", "Important"},
+		"Personal": {"Important", "Optional"},
+		"Hobbies":  {"Optional"},
+	}
+)
+
+const DefaultDBPath = "./notes.db"
+
 type Store struct {
+	Path string // database file, DefaultDBPath when empty
 	conn *sql.DB
+
+	encrypted bool        // titles and bodies are encrypted
+	aead      cipher.AEAD // key of the passphrase, nil while locked
+
+	watch *sql.Conn // connection whose data_version tells about changes
+}
+
+// sqliteDSN is the file: URI of the database at path with the query added,
+// the path is escaped so a ? or # in it is not taken for the query
+func sqliteDSN(path, query string) string {
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath()
+	if query != "" {
+		dsn += "?" + query
+	}
+	return dsn
 }
 
 func (s *Store) Init() error {
+	if s.Path == "" {
+		s.Path = DefaultDBPath
+	}
+
+	// WAL lets readers and a writer of other processes work at the same
+	// time, the busy timeout waits for their locks instead of failing
 	var err error
-	s.conn, err = sql.Open("sqlite3", "./notes.db")
+	s.conn, err = sql.Open("sqlite3", sqliteDSN(s.Path, "_journal_mode=WAL&_busy_timeout=5000"))
 	if err != nil {
 		return err
 	}
@@ -100,13 +156,6 @@ func (s *Store) Init() error {
 	}
 
 	// Insert mock projects if none exist
-	mockProjects := []Project{
-		{Name: "Work", Description: "Work-related tasks"},
-		{Name: "Personal", Description: "Personal notes and ideas"},
-		{Name: "Hobbies", Description: "Notes for hobbies and interests"},
-		{Name: "General", Description: "Notes for general idea"},
-	}
-
 	for _, project := range mockProjects {
 		if err := s.SaveProject(project); err != nil {
 			// Ignore duplicate entries
@@ -115,12 +164,6 @@ func (s *Store) Init() error {
 	}
 
 	// Insert mock categories and project categories
-	mockCategories := []Category{
-		{Name: "Urgent"},
-		{Name: "Important"},
-		{Name: "Optional"},
-	}
-
 	for _, category := range mockCategories {
 		query := `INSERT OR IGNORE INTO Categories (Name) VALUES (?);`
 		if _, err := s.conn.Exec(query, category.Name); err != nil {
@@ -129,12 +172,6 @@ func (s *Store) Init() error {
 	}
 
 	// Link categories to projects (mock)
-	mockAssignments := map[string][]string{
-		"Work":     {"Urgent", "Important"},
-		"Personal": {"Important", "Optional"},
-		"Hobbies":  {"Optional"},
-	}
-
 	for projectName, categoryNames := range mockAssignments {
 		project, err := s.GetProjectByName(projectName)
 		if err != nil || project.Id == 0 {
@@ -155,9 +192,86 @@ func (s *Store) Init() error {
 		}
 	}
 
+	if err = s.initRecurrences(); err != nil {
+		return err
+	}
+
+	if err = s.initTemplates(); err != nil {
+		return err
+	}
+
+	if err = s.initBudgets(); err != nil {
+		return err
+	}
+
+	if err = s.initInvoices(); err != nil {
+		return err
+	}
+
+	if err = s.initMappings(); err != nil {
+		return err
+	}
+
+	if err = s.initEncryption(); err != nil {
+		return err
+	}
+
+	if err = s.addColumn("Notes", "Draft", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+
+	if err = s.initSync(); err != nil {
+		return err
+	}
+
+	if err = s.initPomodoros(); err != nil {
+		return err
+	}
+
+	if err = s.initLinks(); err != nil {
+		return err
+	}
+
+	if err = s.initAttachments(); err != nil {
+		return err
+	}
+
+	if err = s.initTasks(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// addColumn adds a column to an existing table, databases created by older
+// versions do not have it yet
+func (s *Store) addColumn(table, column, definition string) error {
+	rows, err := s.conn.Query(fmt.Sprintf("PRAGMA table_info(%s);", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid          int
+			name, ctype  string
+			notNull, pk  int
+			defaultValue sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	rows.Close()
+
+	_, err = s.conn.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table, column, definition))
+	return err
+}
+
 /*
 func (s *Store) GetNotes() ([]Note, error) {
 	rows, err := s.conn.Query("SELECT Id, Title, Body, TotalTime, CreatedAt, UpdatedAt FROM Notes")
@@ -177,21 +291,19 @@ func (s *Store) GetNotes() ([]Note, error) {
 }
 */
 
-func (s *Store) GetNotes() ([]Note, error) {
-	query := `
+// noteQuery selects the columns read by scanNotes, callers add the WHERE clause
+const noteQuery = `
         SELECT
-			n.Id, n.Title, n.Body, n.TotalTime, n.CreatedAt, n.UpdatedAt,
+			n.Id, n.Title, n.Body, n.TotalTime, n.CreatedAt, n.UpdatedAt, COALESCE(n.RecurrenceId, 0),
+			n.Billable, COALESCE(n.InvoiceId, 0), n.Draft,
 			p.Id AS ProjectId, p.Name AS ProjectName, p.Description AS ProjectDescription,
-			c.Id AS CategoryId, c.Name AS CategoryName
+			COALESCE(c.Id, 0) AS CategoryId, COALESCE(c.Name, '') AS CategoryName
 		FROM Notes n
 		INNER JOIN Projects p ON n.ProjectId = p.Id
-		LEFT JOIN Categories c ON n.CategoryId = c.Id;
+		LEFT JOIN Categories c ON n.CategoryId = c.Id
 	`
 
-	rows, err := s.conn.Query(query)
-	if err != nil {
-		return nil, err
-	}
+func (s *Store) scanNotes(rows *sql.Rows) ([]Note, error) {
 	defer rows.Close()
 
 	var notes []Note
@@ -200,7 +312,8 @@ func (s *Store) GetNotes() ([]Note, error) {
 		var project Project
 		var category Category
 		if err := rows.Scan(
-			&note.Id, &note.Title, &note.Body, &note.TotalTime, &note.CreatedAt, &note.UpdatedAt,
+			&note.Id, &note.Title, &note.Body, &note.TotalTime, &note.CreatedAt, &note.UpdatedAt, &note.RecurrenceId,
+			&note.Billable, &note.InvoiceId, &note.Draft,
 			&project.Id, &project.Name, &project.Description,
 			&category.Id, &category.Name,
 		); err != nil {
@@ -210,7 +323,18 @@ func (s *Store) GetNotes() ([]Note, error) {
 		note.Category = category // Attach the category detail to the note
 		notes = append(notes, note)
 	}
-	return notes, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return s.decryptNotes(notes)
+}
+
+func (s *Store) GetNotes() ([]Note, error) {
+	rows, err := s.conn.Query(noteQuery + ";")
+	if err != nil {
+		return nil, err
+	}
+	return s.scanNotes(rows)
 }
 
 func (s *Store) SaveNote(note Note) error {
@@ -234,16 +358,26 @@ func (s *Store) SaveNote(note Note) error {
         UpdatedAt=excluded.UpdatedAt;
     `
 
-	if _, err := s.conn.Exec(upsertQuery, note.Id, note.Title, note.Body, note.TotalTime, note.CreatedAt, note.UpdatedAt); err != nil {
+	title, err := s.seal(note.Title, note.Id, "Title")
+	if err != nil {
+		return err
+	}
+	body, err := s.seal(note.Body, note.Id, "Body")
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.conn.Exec(upsertQuery, note.Id, title, body, note.TotalTime, note.CreatedAt, note.UpdatedAt); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// DeleteNote deletes the note and leaves a tombstone so sync deletes it on
+// the other replicas too
 func (s *Store) DeleteNote(noteId string) error {
-	_, err := s.conn.Exec("DELETE FROM notes WHERE id = ?", noteId)
-	return err
+	return s.deleteNoteAt(noteId, time.Now(), time.Time{})
 }
 
 func (s *Store) SaveProject(project Project) error {
@@ -279,19 +413,59 @@ func (s *Store) GetProjects() ([]Project, error) {
 	return projects, nil
 }
 
+// SaveNoteWithProject inserts a new note or updates an existing one. The
+// UpdatedAt of an existing note must be the value it was loaded with, if the
+// row changed since then a *ConflictError is returned. A zero UpdatedAt
+// overwrites without the check.
 func (s *Store) SaveNoteWithProject(note Note, projectId, category int, currentdate time.Time) error {
 	now := time.Now().UTC()
+	loaded := note.UpdatedAt
+
+	before, err := s.linkTarget(note.Id)
+	if err != nil {
+		return err
+	}
+	if before.Id != "" {
+		note.CreatedAt = before.CreatedAt // kept by the update
+	}
+
+	links, err := s.noteLinks(note, currentdate)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if note.Id != "" && !loaded.IsZero() {
+		var updatedAt time.Time
+		err := tx.QueryRow("SELECT UpdatedAt FROM Notes WHERE Id = ?", note.Id).Scan(&updatedAt)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if err == nil && !updatedAt.Equal(loaded) {
+			tx.Rollback()
+			theirs, err := s.GetNoteById(note.Id)
+			if err != nil {
+				return err
+			}
+			return &ConflictError{Mine: note, Theirs: theirs}
+		}
+	}
 
 	if note.Id == "" {
 		note.Id = uuid.New().String()
-		note.CreatedAt = currentdate.UTC()
-		note.UpdatedAt = currentdate.UTC()
-	} else {
-		note.UpdatedAt = now
+		note.CreatedAt = onDate(currentdate, now)
 	}
+	// the time of the save even for notes filed on a past day, changes are
+	// synced by it
+	note.UpdatedAt = now
 
-	upsertQuery := `INSERT INTO Notes (Id, Title, Body, TotalTime, ProjectId, CategoryId, CreatedAt, UpdatedAt)
-    VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	upsertQuery := `INSERT INTO Notes (Id, Title, Body, TotalTime, ProjectId, CategoryId, CreatedAt, UpdatedAt, RecurrenceId, Draft)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
     ON CONFLICT(Id) DO UPDATE
     SET
         Title=excluded.Title,
@@ -299,31 +473,102 @@ func (s *Store) SaveNoteWithProject(note Note, projectId, category int, currentd
         TotalTime=excluded.TotalTime,
         ProjectId=excluded.ProjectId,
         CategoryId=excluded.CategoryId,
-        UpdatedAt=excluded.UpdatedAt;`
+        UpdatedAt=excluded.UpdatedAt,
+        Draft=excluded.Draft;`
+
+	title, err := s.seal(note.Title, note.Id, "Title")
+	if err != nil {
+		return err
+	}
+	body, err := s.seal(note.Body, note.Id, "Body")
+	if err != nil {
+		return err
+	}
 
-	if _, err := s.conn.Exec(upsertQuery, note.Id, note.Title, note.Body, note.TotalTime, projectId, category, note.CreatedAt, note.UpdatedAt); err != nil {
+	if _, err := tx.Exec(upsertQuery, note.Id, title, body, note.TotalTime, projectId, nullableId(category), note.CreatedAt, note.UpdatedAt, nullableId(note.RecurrenceId), note.Draft); err != nil {
 		return err
 	}
 
-	return nil
+	if err := saveLinks(tx, note.Id, links); err != nil {
+		return err
+	}
+	if err := saveTasks(tx, note.Id, note.Body); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return s.relinkChanged(before, note)
 }
 
-func (s *Store) GetNotesByProject(projectId int) ([]Note, error) {
-	rows, err := s.conn.Query(
-		"SELECT Id, Title, Body, TotalTime, CreatedAt, UpdatedAt FROM Notes WHERE ProjectId = ?", projectId)
+// onDate keeps the day of date and the time of day of now, so notes of the
+// same day still sort by creation order
+func onDate(date, now time.Time) time.Time {
+	day := date.UTC().Truncate(24 * time.Hour)
+	return day.Add(now.UTC().Sub(now.UTC().Truncate(24 * time.Hour)))
+}
+
+// MoveNote files an existing note under another date
+func (s *Store) MoveNote(note Note, date time.Time) error {
+	// links of and to the note depend on its date
+	moved, err := s.GetNoteById(note.Id)
+	if err != nil || moved.Id == "" {
+		return err
+	}
+	moved.CreatedAt = onDate(date, moved.CreatedAt)
+	links, err := s.noteLinks(moved, date)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
 
-	notes := []Note{}
-	for rows.Next() {
-		var note Note
-		rows.Scan(&note.Id, &note.Title, &note.Body, &note.TotalTime, &note.CreatedAt, &note.UpdatedAt)
-		notes = append(notes, note)
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	return notes, nil
+	query := `UPDATE Notes SET CreatedAt = ?, UpdatedAt = ? WHERE Id = ?;`
+	if _, err := tx.Exec(query, moved.CreatedAt, time.Now().UTC(), moved.Id); err != nil {
+		return err
+	}
+	if err := saveLinks(tx, moved.Id, links); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return s.relink(moved.Id, moved.Title)
+}
+
+// CopyNote saves a duplicate of the note on another date
+func (s *Store) CopyNote(note Note, date time.Time) error {
+	note.Id = ""
+	note.RecurrenceId = 0
+	return s.SaveNoteWithProject(note, note.Project.Id, note.Category.Id, date)
+}
+
+// CopyNotesByDate duplicates every note of one day onto another day
+func (s *Store) CopyNotesByDate(from, to time.Time) (int, error) {
+	notes, err := s.GetNotesByDate(from)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, note := range notes {
+		if err := s.CopyNote(note, to); err != nil {
+			return 0, err
+		}
+	}
+	return len(notes), nil
+}
+
+func (s *Store) GetNotesByProject(projectId int) ([]Note, error) {
+	rows, err := s.conn.Query(noteQuery+"WHERE n.ProjectId = ? ORDER BY n.CreatedAt;", projectId)
+	if err != nil {
+		return nil, err
+	}
+	return s.scanNotes(rows)
 }
 
 func (s *Store) GetProjectById(projectId int) (Project, error) {
@@ -351,6 +596,17 @@ func (s *Store) AssignCategoriesToProject(projectId int, categoryIds []int) erro
 	return nil
 }
 
+// SaveCategory creates the category if needed and returns it
+func (s *Store) SaveCategory(name string) (Category, error) {
+	if _, err := s.conn.Exec(`INSERT OR IGNORE INTO Categories (Name) VALUES (?);`, name); err != nil {
+		return Category{}, err
+	}
+
+	category := Category{Name: name}
+	err := s.conn.QueryRow(`SELECT Id FROM Categories WHERE Name = ?`, name).Scan(&category.Id)
+	return category, err
+}
+
 func (s *Store) GetCategoriesByProject(projectId int) ([]Category, error) {
 	query := `
         SELECT c.Id, c.Name
@@ -392,46 +648,46 @@ func (s *Store) GetProjectByName(name string) (Project, error) {
 func (s *Store) UpdateNoteCategory(noteId string, categoryId int) error {
 	query := `
 		UPDATE Notes
-		SET CategoryId = ?
+		SET CategoryId = ?, UpdatedAt = ?
 		WHERE Id = ?;
 	`
-	_, err := s.conn.Exec(query, categoryId, noteId)
+	_, err := s.conn.Exec(query, categoryId, time.Now().UTC(), noteId)
 	return err
 }
 
 func (s *Store) GetNotesByDate(currentDate time.Time) ([]Note, error) {
-	query := `
-        SELECT
-			n.Id, n.Title, n.Body, n.TotalTime, n.CreatedAt, n.UpdatedAt,
-			p.Id AS ProjectId, p.Name AS ProjectName, p.Description AS ProjectDescription,
-			c.Id AS CategoryId, c.Name AS CategoryName
-		FROM Notes n
-		INNER JOIN Projects p ON n.ProjectId = p.Id
-		LEFT JOIN Categories c ON n.CategoryId = c.Id
-		WHERE date(n.CreatedAt) = date(?);
-	`
-
-	rows, err := s.conn.Query(query, currentDate.UTC().Format("2006-01-02"))
+	rows, err := s.conn.Query(noteQuery+"WHERE date(n.CreatedAt) = date(?);", currentDate.UTC().Format("2006-01-02"))
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return s.scanNotes(rows)
+}
 
-	var notes []Note
-	for rows.Next() {
-		var note Note
-		var project Project
-		var category Category
-		if err := rows.Scan(
-			&note.Id, &note.Title, &note.Body, &note.TotalTime, &note.CreatedAt, &note.UpdatedAt,
-			&project.Id, &project.Name, &project.Description,
-			&category.Id, &category.Name,
-		); err != nil {
-			return nil, err
-		}
-		note.Project = project // Attach the project details to the note
-		note.Category = category // Attach the category details to the note
-		notes = append(notes, note)
+// GetNoteById returns the note, or a zero Note when it does not exist
+func (s *Store) GetNoteById(noteId string) (Note, error) {
+	rows, err := s.conn.Query(noteQuery+"WHERE n.Id = ?;", noteId)
+	if err != nil {
+		return Note{}, err
 	}
-	return notes, nil
+	notes, err := s.scanNotes(rows)
+	if err != nil || len(notes) == 0 {
+		return Note{}, err
+	}
+	return notes[0], nil
+}
+
+// SetNoteDraft marks a note as reviewed, or back to draft
+func (s *Store) SetNoteDraft(noteId string, draft bool) error {
+	_, err := s.conn.Exec("UPDATE Notes SET Draft = ?, UpdatedAt = ? WHERE Id = ?", draft, time.Now().UTC(), noteId)
+	return err
+}
+
+// GetNotesByDateRange returns the notes of every day from from to to, both included
+func (s *Store) GetNotesByDateRange(from, to time.Time) ([]Note, error) {
+	rows, err := s.conn.Query(noteQuery+"WHERE date(n.CreatedAt) BETWEEN date(?) AND date(?) ORDER BY n.CreatedAt;",
+		from.UTC().Format("2006-01-02"), to.UTC().Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	return s.scanNotes(rows)
 }
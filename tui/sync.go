@@ -0,0 +1,636 @@
+package tui
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SyncChange is a note, or the tombstone of a deleted one, as it travels
+// between replicas. Projects and categories go by name since their ids
+// differ from database to database.
+type SyncChange struct {
+	Id        string    `json:"id"`
+	Title     string    `json:"title,omitempty"`
+	Body      string    `json:"body,omitempty"`
+	TotalTime string    `json:"total_time,omitempty"`
+	Project   string    `json:"project,omitempty"`
+	Category  string    `json:"category,omitempty"`
+	Billable  bool      `json:"billable,omitempty"`
+	Draft     bool      `json:"draft,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"` // for tombstones the deletion time
+	Deleted   bool      `json:"deleted,omitempty"`
+}
+
+// Changeset holds the changes a replica made after Since, up to Until
+type Changeset struct {
+	Replica string       `json:"replica"`
+	Since   time.Time    `json:"since"`
+	Until   time.Time    `json:"until"`
+	Changes []SyncChange `json:"changes"`
+
+	// Name is the file a DirTransport read the changeset from, the files
+	// applied are remembered per peer
+	Name string `json:"-"`
+}
+
+// SyncConflict is a note both replicas changed, Kept tells which version won
+type SyncConflict struct {
+	Id     string
+	Title  string
+	Kept   string // local or remote
+	Reason string
+}
+
+type SyncReport struct {
+	Sent      int
+	Received  int // changes applied locally
+	Deleted   int
+	Conflicts []SyncConflict
+}
+
+// errEncryptedDirSync keeps decrypted notes out of shared folders, changesets
+// hold titles and bodies in the clear
+var errEncryptedDirSync = errors.New("notes are encrypted, they are not synced through a folder where they would be stored decrypted, sync with -url instead")
+
+// SyncTransport carries changesets to another replica and back
+type SyncTransport interface {
+	// Peer names the other side, the time of the last sync is kept per peer
+	Peer() string
+	// Exchange hands over the local changes and returns the remote changes
+	// the local replica has not seen yet
+	Exchange(local Changeset) ([]Changeset, error)
+}
+
+func (s *Store) initSync() error {
+	createTableDeletedNotesStmt := `
+        CREATE TABLE IF NOT EXISTS DeletedNotes (
+            Id TEXT NOT NULL PRIMARY KEY,
+            DeletedAt TIMESTAMP NOT NULL,
+            ReceivedAt TIMESTAMP
+        );`
+
+	createTableSyncStateStmt := `
+        CREATE TABLE IF NOT EXISTS SyncState (
+            Key TEXT NOT NULL PRIMARY KEY,
+            Value TEXT NOT NULL
+        );`
+
+	if _, err := s.conn.Exec(createTableDeletedNotesStmt); err != nil {
+		return err
+	}
+
+	if _, err := s.conn.Exec(createTableSyncStateStmt); err != nil {
+		return err
+	}
+
+	// when a change from another replica arrived, so it is passed on to the
+	// replicas that synced after the change was made
+	if err := s.addColumn("Notes", "ReceivedAt", "TIMESTAMP"); err != nil {
+		return err
+	}
+
+	// every database is a replica with its own id
+	_, err := s.conn.Exec("INSERT OR IGNORE INTO SyncState (Key, Value) VALUES ('replica', ?);", uuid.New().String())
+	return err
+}
+
+// ReplicaId returns the id of this database in syncs
+func (s *Store) ReplicaId() (string, error) {
+	var id string
+	err := s.conn.QueryRow("SELECT Value FROM SyncState WHERE Key = 'replica';").Scan(&id)
+	return id, err
+}
+
+func (s *Store) lastSync(peer string) (time.Time, error) {
+	var value string
+	err := s.conn.QueryRow("SELECT Value FROM SyncState WHERE Key = ?;", "last_sync:"+peer).Scan(&value)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	} else if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// changesetApplied tells whether the named changeset of peer was applied
+func (s *Store) changesetApplied(peer, name string) (bool, error) {
+	var count int
+	err := s.conn.QueryRow("SELECT count(*) FROM SyncState WHERE Key = ?;", "applied:"+peer+":"+name).Scan(&count)
+	return count > 0, err
+}
+
+func (s *Store) setChangesetApplied(peer, name string) error {
+	_, err := s.conn.Exec("INSERT OR IGNORE INTO SyncState (Key, Value) VALUES (?, ?);",
+		"applied:"+peer+":"+name, time.Now().UTC().Format(time.RFC3339Nano))
+	return err
+}
+
+func (s *Store) setLastSync(peer string, at time.Time) error {
+	_, err := s.conn.Exec(`
+        INSERT INTO SyncState (Key, Value) VALUES (?, ?)
+        ON CONFLICT(Key) DO UPDATE SET Value = excluded.Value;`,
+		"last_sync:"+peer, at.UTC().Format(time.RFC3339Nano))
+	return err
+}
+
+// Changes returns the notes changed and deleted after since
+func (s *Store) Changes(since time.Time) (Changeset, error) {
+	replica, err := s.ReplicaId()
+	if err != nil {
+		return Changeset{}, err
+	}
+	changeset := Changeset{Replica: replica, Since: since, Until: time.Now().UTC(), Changes: []SyncChange{}}
+
+	rows, err := s.conn.Query(noteQuery+"WHERE n.UpdatedAt > ? OR n.ReceivedAt > ? ORDER BY n.UpdatedAt;", since.UTC(), since.UTC())
+	if err != nil {
+		return Changeset{}, err
+	}
+	notes, err := s.scanNotes(rows)
+	if err != nil {
+		return Changeset{}, err
+	}
+	for _, note := range notes {
+		changeset.Changes = append(changeset.Changes, toSyncChange(note))
+	}
+
+	rows, err = s.conn.Query("SELECT Id, DeletedAt FROM DeletedNotes WHERE DeletedAt > ? OR ReceivedAt > ?;", since.UTC(), since.UTC())
+	if err != nil {
+		return Changeset{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		change := SyncChange{Deleted: true}
+		if err := rows.Scan(&change.Id, &change.UpdatedAt); err != nil {
+			return Changeset{}, err
+		}
+		changeset.Changes = append(changeset.Changes, change)
+	}
+	return changeset, rows.Err()
+}
+
+func toSyncChange(note Note) SyncChange {
+	return SyncChange{
+		Id:        note.Id,
+		Title:     note.Title,
+		Body:      note.Body,
+		TotalTime: note.TotalTime,
+		Project:   note.Project.Name,
+		Category:  note.Category.Name,
+		Billable:  note.Billable,
+		Draft:     note.Draft,
+		CreatedAt: note.CreatedAt.UTC(),
+		UpdatedAt: note.UpdatedAt.UTC(),
+	}
+}
+
+// contentHash identifies what a change says apart from when it was made, it
+// breaks ties between changes with the same UpdatedAt
+func (c SyncChange) contentHash() string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		c.Title, c.Body, c.TotalTime, c.Project, c.Category,
+		fmt.Sprint(c.Billable, c.Draft, c.Deleted), c.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// newer decides between two versions of a note the same way on every replica
+func (c SyncChange) newer(other SyncChange) bool {
+	if !c.UpdatedAt.Equal(other.UpdatedAt) {
+		return c.UpdatedAt.After(other.UpdatedAt)
+	}
+	return c.contentHash() > other.contentHash()
+}
+
+// Sync exchanges the changes since the last sync with the peer of transport
+func (s *Store) Sync(transport SyncTransport) (SyncReport, error) {
+	if _, ok := transport.(DirTransport); ok && s.encrypted {
+		return SyncReport{}, errEncryptedDirSync
+	}
+
+	since, err := s.lastSync(transport.Peer())
+	if err != nil {
+		return SyncReport{}, err
+	}
+
+	local, err := s.Changes(since)
+	if err != nil {
+		return SyncReport{}, err
+	}
+
+	remote, err := transport.Exchange(local)
+	if err != nil {
+		return SyncReport{}, err
+	}
+
+	// named changesets are applied once each, whatever their times say:
+	// they come from other clocks and a synced folder may deliver them late
+	report := SyncReport{Sent: len(local.Changes)}
+	for _, changeset := range remote {
+		if changeset.Name != "" {
+			applied, err := s.changesetApplied(transport.Peer(), changeset.Name)
+			if err != nil {
+				return report, err
+			}
+			if applied {
+				continue
+			}
+		}
+		if err := s.ApplyChanges(changeset, since, &report); err != nil {
+			return report, err
+		}
+		if changeset.Name != "" {
+			if err := s.setChangesetApplied(transport.Peer(), changeset.Name); err != nil {
+				return report, err
+			}
+		}
+	}
+	return report, s.setLastSync(transport.Peer(), local.Until)
+}
+
+// ServeSync is the other side of an HTTP sync: it applies the changes of the
+// remote replica and returns the local changes it has not seen yet
+func (s *Store) ServeSync(remote Changeset) (Changeset, SyncReport, error) {
+	peer := "replica:" + remote.Replica
+	since, err := s.lastSync(peer)
+	if err != nil {
+		return Changeset{}, SyncReport{}, err
+	}
+
+	// taken before applying, so the remote changes do not travel back
+	local, err := s.Changes(since)
+	if err != nil {
+		return Changeset{}, SyncReport{}, err
+	}
+
+	report := SyncReport{Sent: len(local.Changes)}
+	if err := s.ApplyChanges(remote, since, &report); err != nil {
+		return Changeset{}, report, err
+	}
+	return local, report, s.setLastSync(peer, local.Until)
+}
+
+// ApplyChanges merges a remote changeset. since is the last sync with that
+// replica, a note changed on both sides after it is a conflict: the newer
+// version wins and the conflict is reported. Invoiced notes are never changed.
+func (s *Store) ApplyChanges(changeset Changeset, since time.Time, report *SyncReport) error {
+	for _, change := range changeset.Changes {
+		local, err := s.GetNoteById(change.Id)
+		if err != nil {
+			return err
+		}
+
+		if local.Id == "" {
+			if err := s.applyToMissing(change, report); err != nil {
+				return err
+			}
+			continue
+		}
+
+		mine := toSyncChange(local)
+		if !change.Deleted && mine.contentHash() == change.contentHash() {
+			continue // same content, e.g. the echo of an earlier sync
+		}
+
+		if local.InvoiceId != 0 {
+			report.Conflicts = append(report.Conflicts, SyncConflict{
+				Id: local.Id, Title: local.Title, Kept: "local", Reason: "the note is invoiced here",
+			})
+			continue
+		}
+
+		changedHere := local.UpdatedAt.After(since)
+		if !change.newer(mine) {
+			if changedHere {
+				report.Conflicts = append(report.Conflicts, SyncConflict{
+					Id: local.Id, Title: local.Title, Kept: "local", Reason: conflictReason(change),
+				})
+			}
+			continue
+		}
+		if changedHere {
+			report.Conflicts = append(report.Conflicts, SyncConflict{
+				Id: local.Id, Title: local.Title, Kept: "remote", Reason: conflictReason(change),
+			})
+		}
+
+		if change.Deleted {
+			if err := s.deleteNoteAt(change.Id, change.UpdatedAt, time.Now()); err != nil {
+				return err
+			}
+			report.Deleted++
+			continue
+		}
+		if err := s.applyChange(change); err != nil {
+			return err
+		}
+		report.Received++
+	}
+	return nil
+}
+
+func conflictReason(change SyncChange) string {
+	if change.Deleted {
+		return "deleted there, changed here"
+	}
+	return "changed on both sides"
+}
+
+// applyToMissing applies a change to a note this replica does not have, it
+// may have been deleted here
+func (s *Store) applyToMissing(change SyncChange, report *SyncReport) error {
+	var deletedAt time.Time
+	err := s.conn.QueryRow("SELECT DeletedAt FROM DeletedNotes WHERE Id = ?;", change.Id).Scan(&deletedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	deletedHere := err == nil
+
+	switch {
+	case change.Deleted && !deletedHere:
+		// never seen here, keep the tombstone for the next peer
+		_, err := s.conn.Exec("INSERT OR IGNORE INTO DeletedNotes (Id, DeletedAt, ReceivedAt) VALUES (?, ?, ?);",
+			change.Id, change.UpdatedAt.UTC(), time.Now().UTC())
+		return err
+	case change.Deleted:
+		return nil
+	case deletedHere && !change.UpdatedAt.After(deletedAt):
+		return nil // the delete is newer
+	}
+
+	if err := s.applyChange(change); err != nil {
+		return err
+	}
+	if deletedHere {
+		report.Conflicts = append(report.Conflicts, SyncConflict{
+			Id: change.Id, Title: change.Title, Kept: "remote", Reason: "deleted here, changed there",
+		})
+	}
+	report.Received++
+	return nil
+}
+
+// applyChange writes the remote version of a note as it is, UpdatedAt included
+func (s *Store) applyChange(change SyncChange) error {
+	if err := s.SaveProject(Project{Name: change.Project}); err != nil {
+		return err
+	}
+	project, err := s.GetProjectByName(change.Project)
+	if err != nil {
+		return err
+	}
+	if project.Id == 0 {
+		return fmt.Errorf("unable to create project %q", change.Project)
+	}
+
+	categoryId := 0
+	if change.Category != "" {
+		category, err := s.SaveCategory(change.Category)
+		if err != nil {
+			return err
+		}
+		if err := s.AssignCategoriesToProject(project.Id, []int{category.Id}); err != nil {
+			return err
+		}
+		categoryId = category.Id
+	}
+
+	before, err := s.linkTarget(change.Id)
+	if err != nil {
+		return err
+	}
+	links, err := s.noteLinks(Note{Id: change.Id, Body: change.Body, CreatedAt: change.CreatedAt}, change.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	title, err := s.seal(change.Title, change.Id, "Title")
+	if err != nil {
+		return err
+	}
+	body, err := s.seal(change.Body, change.Id, "Body")
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+        INSERT INTO Notes (Id, Title, Body, TotalTime, ProjectId, CategoryId, CreatedAt, UpdatedAt, Billable, Draft, ReceivedAt)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+        ON CONFLICT(Id) DO UPDATE
+        SET
+            Title=excluded.Title,
+            Body=excluded.Body,
+            TotalTime=excluded.TotalTime,
+            ProjectId=excluded.ProjectId,
+            CategoryId=excluded.CategoryId,
+            CreatedAt=excluded.CreatedAt,
+            UpdatedAt=excluded.UpdatedAt,
+            Billable=excluded.Billable,
+            Draft=excluded.Draft,
+            ReceivedAt=excluded.ReceivedAt;`,
+		change.Id, title, body, change.TotalTime, project.Id, nullableId(categoryId),
+		change.CreatedAt.UTC(), change.UpdatedAt.UTC(), change.Billable, change.Draft, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM DeletedNotes WHERE Id = ?;", change.Id); err != nil {
+		return err
+	}
+	if err := saveLinks(tx, change.Id, links); err != nil {
+		return err
+	}
+	if err := saveTasks(tx, change.Id, change.Body); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return s.relinkChanged(before, Note{Id: change.Id, Title: change.Title, CreatedAt: change.CreatedAt})
+}
+
+// deleteNoteAt deletes the note and leaves a tombstone with the given time,
+// received is set for deletes that came from another replica
+func (s *Store) deleteNoteAt(noteId string, at, received time.Time) error {
+	hashes, err := s.attachmentHashes(noteId)
+	if err != nil {
+		return err
+	}
+	deleted, err := s.linkTarget(noteId)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM Notes WHERE Id = ?;", noteId); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM Attachments WHERE NoteId = ?;", noteId); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM NoteLinks WHERE FromId = ? OR ToId = ?;", noteId, noteId); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM Tasks WHERE NoteId = ?;", noteId); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+        INSERT INTO DeletedNotes (Id, DeletedAt, ReceivedAt) VALUES (?, ?, ?)
+        ON CONFLICT(Id) DO UPDATE SET DeletedAt = excluded.DeletedAt, ReceivedAt = excluded.ReceivedAt;`,
+		noteId, at.UTC(), nullableTime(received))
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// a [[title]] of the note may mean another note with the title now
+	if deleted.Id != "" {
+		if err := s.relink(noteId, deleted.Title); err != nil {
+			return err
+		}
+	}
+
+	// files are removed once the rows are gone, a failure leaves files
+	// for PruneAttachments
+	return s.removeBlobs(hashes)
+}
+
+// DirTransport syncs through a directory every replica can reach, e.g. a
+// network share or a synced folder. Each sync leaves a changeset file there.
+type DirTransport struct {
+	Dir string
+}
+
+func (t DirTransport) Peer() string {
+	abs, err := filepath.Abs(t.Dir)
+	if err != nil {
+		abs = t.Dir
+	}
+	return "dir:" + abs
+}
+
+func (t DirTransport) Exchange(local Changeset) ([]Changeset, error) {
+	if err := os.MkdirAll(t.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	// every changeset of the other replicas, Sync skips those it applied
+	paths, err := filepath.Glob(filepath.Join(t.Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var remote []Changeset
+	for _, path := range paths {
+		if strings.HasPrefix(filepath.Base(path), local.Replica+"-") {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var changeset Changeset
+		if err := json.Unmarshal(data, &changeset); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		changeset.Name = filepath.Base(path)
+		remote = append(remote, changeset)
+	}
+	sort.Slice(remote, func(i, j int) bool { return remote[i].Until.Before(remote[j].Until) })
+
+	if len(local.Changes) > 0 {
+		data, err := json.MarshalIndent(local, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		name := local.Replica + "-" + local.Until.Format("20060102T150405.000000000Z") + ".json"
+		if err := writeFileAtomic(filepath.Join(t.Dir, name), data); err != nil {
+			return nil, err
+		}
+	}
+	return remote, nil
+}
+
+// HTTPTransport syncs with the notes serve of another machine
+type HTTPTransport struct {
+	URL    string // e.g. http://workstation:8080
+	Token  string // api_token of the peer
+	Client *http.Client
+}
+
+func (t HTTPTransport) Peer() string {
+	return "http:" + strings.TrimSuffix(t.URL, "/")
+}
+
+func (t HTTPTransport) Exchange(local Changeset) ([]Changeset, error) {
+	data, err := json.Marshal(local)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(t.URL, "/")+"/api/sync", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+t.Token)
+
+	client := t.Client
+	if client == nil {
+		client = &http.Client{Timeout: time.Minute}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		return nil, fmt.Errorf("%s: %s %s", t.URL, resp.Status, apiErr.Error)
+	}
+
+	var remote Changeset
+	if err := json.NewDecoder(resp.Body).Decode(&remote); err != nil {
+		return nil, err
+	}
+	if remote.Replica == local.Replica {
+		return nil, errors.New("the peer is this database")
+	}
+	return []Changeset{remote}, nil
+}
+
+func nullableTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
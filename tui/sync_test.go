@@ -0,0 +1,310 @@
+package tui
+
+import (
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// syncPair is two replicas and a way to sync them until both saw every change
+type syncPair struct {
+	a, b *Store
+	sync func(t *testing.T) []SyncReport
+}
+
+func newHTTPSyncPair(t *testing.T) syncPair {
+	a, b := newTestStore(t), newTestStore(t)
+	server := httptest.NewServer(NewAPIHandler(b, testToken))
+	t.Cleanup(server.Close)
+
+	return syncPair{a: a, b: b, sync: func(t *testing.T) []SyncReport {
+		t.Helper()
+		// one exchange carries the changes both ways
+		report, err := a.Sync(HTTPTransport{URL: server.URL, Token: testToken})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return []SyncReport{report}
+	}}
+}
+
+func newDirSyncPair(t *testing.T) syncPair {
+	a, b := newTestStore(t), newTestStore(t)
+	dir := t.TempDir()
+
+	return syncPair{a: a, b: b, sync: func(t *testing.T) []SyncReport {
+		t.Helper()
+		// a leaves its changes, b takes them and leaves its own, a takes those
+		var reports []SyncReport
+		for _, s := range []*Store{a, b, a} {
+			report, err := s.Sync(DirTransport{Dir: dir})
+			if err != nil {
+				t.Fatal(err)
+			}
+			reports = append(reports, report)
+		}
+		return reports
+	}}
+}
+
+func forEachTransport(t *testing.T, test func(t *testing.T, p syncPair)) {
+	t.Run("http", func(t *testing.T) { test(t, newHTTPSyncPair(t)) })
+	t.Run("dir", func(t *testing.T) { test(t, newDirSyncPair(t)) })
+}
+
+func saveTestNote(t *testing.T, s *Store, title, body string, project Project, category Category) Note {
+	t.Helper()
+	if err := s.SaveNoteWithProject(Note{Title: title, Body: body, TotalTime: "1h"}, project.Id, category.Id, today()); err != nil {
+		t.Fatal(err)
+	}
+	notes, err := s.GetNotesByDate(today())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, note := range notes {
+		if note.Title == title {
+			return note
+		}
+	}
+	t.Fatalf("note %q was not saved", title)
+	return Note{}
+}
+
+func updateTestNote(t *testing.T, s *Store, id, body string) {
+	t.Helper()
+	note := getTestNote(t, s, id)
+	note.Body = body
+	if err := s.SaveNoteWithProject(note, note.Project.Id, note.Category.Id, note.CreatedAt); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func getTestNote(t *testing.T, s *Store, id string) Note {
+	t.Helper()
+	note, err := s.GetNoteById(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return note
+}
+
+func conflicts(reports []SyncReport) []SyncConflict {
+	var all []SyncConflict
+	for _, report := range reports {
+		all = append(all, report.Conflicts...)
+	}
+	return all
+}
+
+func TestSyncCreateUpdateDelete(t *testing.T) {
+	forEachTransport(t, func(t *testing.T, p syncPair) {
+		work, urgent := testProject(t, p.a, "Work")
+		note := saveTestNote(t, p.a, "Release", "- [ ] tag", work, urgent)
+
+		p.sync(t)
+		got := getTestNote(t, p.b, note.Id)
+		if got.Title != "Release" || got.Body != "- [ ] tag" || got.Project.Name != "Work" || got.Category.Name != urgent.Name {
+			t.Fatalf("create: b has %+v", got)
+		}
+
+		updateTestNote(t, p.b, note.Id, "- [x] tag")
+		p.sync(t)
+		if got := getTestNote(t, p.a, note.Id); got.Body != "- [x] tag" {
+			t.Fatalf("update: a has body %q", got.Body)
+		}
+
+		if err := p.a.DeleteNote(note.Id); err != nil {
+			t.Fatal(err)
+		}
+		p.sync(t)
+		if got := getTestNote(t, p.b, note.Id); got.Id != "" {
+			t.Fatalf("delete: b still has %+v", got)
+		}
+
+		// the tombstone does not bring the note back on the next sync
+		p.sync(t)
+		if got := getTestNote(t, p.a, note.Id); got.Id != "" {
+			t.Errorf("delete: a has the note again")
+		}
+	})
+}
+
+func TestSyncSendsNotesFiledOnPastDays(t *testing.T) {
+	forEachTransport(t, func(t *testing.T, p syncPair) {
+		work, urgent := testProject(t, p.a, "Work")
+		copied := saveTestNote(t, p.a, "Standup", "", work, urgent)
+		p.sync(t)
+
+		past := today().AddDate(0, 0, -3)
+		if err := p.a.SaveNoteWithProject(Note{Title: "Forgotten", TotalTime: "1h"}, work.Id, urgent.Id, past); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.a.CopyNote(copied, past); err != nil {
+			t.Fatal(err)
+		}
+		p.sync(t)
+
+		notes, err := p.b.GetNotesByDate(past)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(notes) != 2 {
+			t.Fatalf("b has %d notes on %s, want 2", len(notes), past.Format(dateLayout))
+		}
+	})
+}
+
+func TestSyncMatchesProjectsAndCategoriesByName(t *testing.T) {
+	forEachTransport(t, func(t *testing.T, p syncPair) {
+		// the project gets another id on each side
+		if err := p.b.SaveProject(Project{Name: "Internal"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.a.SaveProject(Project{Name: "Acme"}); err != nil {
+			t.Fatal(err)
+		}
+		acme, err := p.a.GetProjectByName("Acme")
+		if err != nil {
+			t.Fatal(err)
+		}
+		meetings, err := p.a.SaveCategory("Meetings")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.a.AssignCategoriesToProject(acme.Id, []int{meetings.Id}); err != nil {
+			t.Fatal(err)
+		}
+		note := saveTestNote(t, p.a, "Kickoff", "", acme, meetings)
+
+		p.sync(t)
+		got := getTestNote(t, p.b, note.Id)
+		if got.Project.Name != "Acme" || got.Category.Name != "Meetings" {
+			t.Fatalf("b has project %q and category %q", got.Project.Name, got.Category.Name)
+		}
+		if got.Project.Id == acme.Id {
+			t.Errorf("project ids are the same on both sides, the name was not matched")
+		}
+		categories, err := p.b.GetCategoriesByProject(got.Project.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(categories) != 1 || categories[0].Name != "Meetings" {
+			t.Errorf("b has categories %+v for Acme", categories)
+		}
+	})
+}
+
+func TestSyncConflictIsDecidedTheSameOnBothSides(t *testing.T) {
+	forEachTransport(t, func(t *testing.T, p syncPair) {
+		work, urgent := testProject(t, p.a, "Work")
+		note := saveTestNote(t, p.a, "Plan", "first", work, urgent)
+		p.sync(t)
+
+		updateTestNote(t, p.a, note.Id, "edited on a")
+		updateTestNote(t, p.b, note.Id, "edited on b") // the newer one
+		reports := p.sync(t)
+
+		a, b := getTestNote(t, p.a, note.Id), getTestNote(t, p.b, note.Id)
+		if a.Body != "edited on b" || b.Body != "edited on b" {
+			t.Fatalf("a has %q and b has %q, want both %q", a.Body, b.Body, "edited on b")
+		}
+		if !a.UpdatedAt.Equal(b.UpdatedAt) {
+			t.Errorf("UpdatedAt differs: %s and %s", a.UpdatedAt, b.UpdatedAt)
+		}
+		if found := conflicts(reports); len(found) == 0 || found[0].Id != note.Id {
+			t.Errorf("conflict not reported: %+v", found)
+		}
+	})
+}
+
+func TestSyncTieBreakIsTheSameOnBothSides(t *testing.T) {
+	mine := SyncChange{Id: "n", Title: "t", Body: "mine", Project: "Work"}
+	theirs := mine
+	theirs.Body = "theirs"
+	if mine.newer(theirs) == theirs.newer(mine) {
+		t.Errorf("both or neither of two versions with the same UpdatedAt win")
+	}
+}
+
+func TestSyncReportsNoteDeletedHereAndChangedThere(t *testing.T) {
+	p := newDirSyncPair(t)
+	work, urgent := testProject(t, p.a, "Work")
+	note := saveTestNote(t, p.a, "Draft", "v1", work, urgent)
+	p.sync(t)
+
+	if err := p.a.DeleteNote(note.Id); err != nil {
+		t.Fatal(err)
+	}
+	updateTestNote(t, p.b, note.Id, "v2") // after the delete
+
+	found := conflicts(p.sync(t))
+	if got := getTestNote(t, p.a, note.Id); got.Body != "v2" {
+		t.Fatalf("the newer change did not win over the delete, a has %+v", got)
+	}
+	for _, conflict := range found {
+		if conflict.Id == note.Id && conflict.Kept == "remote" && conflict.Reason == "deleted here, changed there" {
+			return
+		}
+	}
+	t.Errorf("conflict not reported: %+v", found)
+}
+
+func TestSyncAppliesLateChangesetFiles(t *testing.T) {
+	a, b := newTestStore(t), newTestStore(t)
+	work, urgent := testProject(t, a, "Work")
+	note := saveTestNote(t, a, "Late", "", work, urgent)
+
+	// a writes its changeset before b syncs, the folder delivers it after
+	outbox, shared := t.TempDir(), t.TempDir()
+	if _, err := a.Sync(DirTransport{Dir: outbox}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Sync(DirTransport{Dir: shared}); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := filepath.Glob(filepath.Join(outbox, "*.json"))
+	if err != nil || len(paths) != 1 {
+		t.Fatalf("a wrote %v: %v", paths, err)
+	}
+	data, err := os.ReadFile(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(shared, filepath.Base(paths[0])), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := b.Sync(DirTransport{Dir: shared})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Received != 1 || getTestNote(t, b, note.Id).Id == "" {
+		t.Fatalf("late changeset was skipped: %+v", report)
+	}
+
+	report, err = b.Sync(DirTransport{Dir: shared})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Received != 0 {
+		t.Errorf("changeset applied twice: %+v", report)
+	}
+}
+
+func TestSyncRefusesFolderWhileEncrypted(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.ChangePassphrase("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if _, err := s.Sync(DirTransport{Dir: dir}); !errors.Is(err, errEncryptedDirSync) {
+		t.Fatalf("got %v, want %v", err, errEncryptedDirSync)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("files were written: %v", entries)
+	}
+}
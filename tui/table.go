@@ -0,0 +1,179 @@
+package tui
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	sortByCreated uint = iota
+	sortByDuration
+	sortByProject
+	sortByTitle
+)
+
+var sortNames = []string{"created", "duration", "project", "title"}
+
+// chrome lines around the table in listView (header, date, help)
+const listViewChrome = 8
+
+func newNoteTable() table.Model {
+	columns := []table.Column{
+		{Title: "Title", Width: 24},
+		{Title: "Project", Width: 12},
+		{Title: "Category", Width: 12},
+		{Title: "Time", Width: 8},
+		{Title: "$", Width: 1},
+		{Title: "Body", Width: 32},
+	}
+
+	// Only navigation keys, the letters are used by listView actions
+	keyMap := table.KeyMap{
+		LineUp:       key.NewBinding(key.WithKeys("up", "k")),
+		LineDown:     key.NewBinding(key.WithKeys("down", "j")),
+		PageUp:       key.NewBinding(key.WithKeys("pgup")),
+		PageDown:     key.NewBinding(key.WithKeys("pgdown")),
+		HalfPageUp:   key.NewBinding(key.WithKeys("ctrl+u")),
+		HalfPageDown: key.NewBinding(key.WithKeys("ctrl+d")),
+		GotoTop:      key.NewBinding(key.WithKeys("home")),
+		GotoBottom:   key.NewBinding(key.WithKeys("end")),
+	}
+
+	styles := table.DefaultStyles()
+	styles.Header = styles.Header.
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderForeground(lipgloss.Color("240")).
+		BorderBottom(true).
+		Bold(false)
+	styles.Selected = styles.Selected.
+		Foreground(lipgloss.Color("229")).
+		Background(lipgloss.Color("99")).
+		Bold(false)
+
+	return table.New(
+		table.WithColumns(columns),
+		table.WithKeyMap(keyMap),
+		table.WithStyles(styles),
+		table.WithFocused(true),
+		table.WithHeight(10),
+	)
+}
+
+func noteRows(notes []Note) []table.Row {
+	rows := make([]table.Row, 0, len(notes))
+	for _, n := range notes {
+		shortBody := strings.ReplaceAll(n.Body, "\n", " ")
+		title := n.Title
+		if n.isPlaceholder() {
+			title = "↻ " + title
+		} else if n.Draft {
+			title = "✎ " + title
+		}
+		billing := ""
+		switch {
+		case n.InvoiceId != 0:
+			billing = "✓" // invoiced
+		case n.Billable:
+			billing = "$"
+		}
+		rows = append(rows, table.Row{
+			title,
+			n.Project.Name,
+			n.Category.Name,
+			n.TotalTime,
+			billing,
+			shortBody,
+		})
+	}
+	return rows
+}
+
+func sortNotes(notes []Note, sortBy uint) {
+	sort.SliceStable(notes, func(i, j int) bool {
+		a, b := notes[i], notes[j]
+		// pending recurring notes stay at the bottom
+		if a.isPlaceholder() != b.isPlaceholder() {
+			return b.isPlaceholder()
+		}
+		switch sortBy {
+		case sortByDuration:
+			da, db := parseTotalTime(a.TotalTime), parseTotalTime(b.TotalTime)
+			if da != db {
+				return da > db // longest first
+			}
+		case sortByProject:
+			pa, pb := strings.ToLower(a.Project.Name), strings.ToLower(b.Project.Name)
+			if pa != pb {
+				return pa < pb
+			}
+		case sortByTitle:
+			ta, tb := strings.ToLower(a.Title), strings.ToLower(b.Title)
+			if ta != tb {
+				return ta < tb
+			}
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+}
+
+// setNotes replaces the displayed notes and keeps the selection on the
+// same note when it is still there
+func (m *model) setNotes(notes []Note) {
+	selected, hasSelected := m.selectedNote()
+
+	sortNotes(notes, m.sortBy)
+	m.notes = notes
+
+	m.listIndex = 0
+	for i, n := range notes {
+		if hasSelected && n.Id == selected.Id && n.RecurrenceId == selected.RecurrenceId {
+			m.listIndex = i
+			break
+		}
+	}
+
+	m.noteTable.SetRows(noteRows(notes))
+	m.noteTable.SetCursor(m.listIndex)
+}
+
+// refreshSelected reloads the selected note after a change in the store, so
+// its UpdatedAt matches and the next save is not taken for a conflict
+func (m *model) refreshSelected(noteId string) {
+	note, err := m.store.GetNoteById(noteId)
+	if err != nil {
+		m.statusMsg = "error: " + err.Error()
+		return
+	}
+	if m.listIndex >= 0 && m.listIndex < len(m.notes) && note.Id != "" {
+		m.notes[m.listIndex] = note
+	}
+	m.setNotes(m.notes)
+}
+
+func (m model) selectedNote() (Note, bool) {
+	if m.listIndex < 0 || m.listIndex >= len(m.notes) {
+		return Note{}, false
+	}
+	return m.notes[m.listIndex], true
+}
+
+func (m *model) resizeNoteTable(width, height int) {
+	if h := height - listViewChrome; h > 3 {
+		m.noteTable.SetHeight(h)
+	}
+
+	// Body takes what is left of the width
+	columns := m.noteTable.Columns()
+	used := 0
+	for _, c := range columns[:len(columns)-1] {
+		used += c.Width + 2 // cell padding
+	}
+	if w := width - used - 2; w > 10 {
+		columns[len(columns)-1].Width = w
+	}
+	m.noteTable.SetColumns(columns)
+}
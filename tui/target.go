@@ -0,0 +1,36 @@
+package tui
+
+import "time"
+
+const (
+	underTarget uint = iota
+	onTarget
+	overTarget
+)
+
+// targetTolerance is how far from the target still counts as on target
+const targetTolerance = 15 * time.Minute
+
+// loggedTime sums the TotalTime of the saved notes, pending recurring notes
+// and drafts are not counted
+func loggedTime(notes []Note) time.Duration {
+	var total time.Duration
+	for _, note := range notes {
+		if note.isPlaceholder() || note.Draft {
+			continue
+		}
+		total += parseTotalTime(note.TotalTime)
+	}
+	return total
+}
+
+func targetStatus(logged, target time.Duration) uint {
+	switch {
+	case logged < target-targetTolerance:
+		return underTarget
+	case logged > target+targetTolerance:
+		return overTarget
+	default:
+		return onTarget
+	}
+}
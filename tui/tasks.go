@@ -0,0 +1,359 @@
+package tui
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// taskPattern matches a checklist item line like "- [ ] text" or "* [x] text"
+var taskPattern = regexp.MustCompile(`^(\s*[-*+] \[)([ xX])\](.*)$`)
+
+// Task is a checklist item of a note body
+type Task struct {
+	Note Note
+	Line int // of the item in the note body, from 0
+	Text string
+	Done bool
+}
+
+// parseTasks returns the checklist items of body, items in code blocks are
+// left out
+func parseTasks(body string) []Task {
+	var tasks []Task
+	fenced := false
+	for i, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+			continue
+		}
+		match := taskPattern.FindStringSubmatch(line)
+		if fenced || match == nil {
+			continue
+		}
+		tasks = append(tasks, Task{Line: i, Text: strings.TrimSpace(match[3]), Done: match[2] != " "})
+	}
+	return tasks
+}
+
+// toggleTask checks or unchecks the item on line of body
+func toggleTask(body string, line int) (string, error) {
+	lines := strings.Split(body, "\n")
+	if line < 0 || line >= len(lines) {
+		return "", fmt.Errorf("the note has no line %d", line+1)
+	}
+	match := taskPattern.FindStringSubmatchIndex(lines[line])
+	if match == nil {
+		return "", fmt.Errorf("line %d of the note is no checklist item", line+1)
+	}
+
+	mark := "x"
+	if lines[line][match[4]:match[5]] != " " {
+		mark = " "
+	}
+	lines[line] = lines[line][:match[4]] + mark + lines[line][match[5]:]
+	return strings.Join(lines, "\n"), nil
+}
+
+// initTasks creates the Tasks index of the checklist items. Like NoteLinks it
+// keeps no text, the items are read from the note bodies when listed, and a
+// new table is filled from the existing notes, which Unlock does when they
+// are locked.
+func (s *Store) initTasks() error {
+	var exists int
+	err := s.conn.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'Tasks';").Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists == 0 && s.Locked() {
+		return nil
+	}
+
+	createTableTasksStmt := `
+        CREATE TABLE IF NOT EXISTS Tasks (
+            NoteId TEXT NOT NULL,
+            Line INTEGER NOT NULL,
+            Done INTEGER NOT NULL DEFAULT 0,
+            PRIMARY KEY (NoteId, Line)
+        );`
+
+	if _, err := s.conn.Exec(createTableTasksStmt); err != nil {
+		return err
+	}
+
+	if exists == 0 {
+		return s.rebuildTasks()
+	}
+	return nil
+}
+
+func (s *Store) rebuildTasks() error {
+	notes, err := s.GetNotes()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, note := range notes {
+		if err := saveTasks(tx, note.Id, note.Body); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// saveTasks replaces the index of the checklist items of the note
+func saveTasks(tx *sql.Tx, noteId, body string) error {
+	if _, err := tx.Exec("DELETE FROM Tasks WHERE NoteId = ?;", noteId); err != nil {
+		return err
+	}
+	for _, task := range parseTasks(body) {
+		if _, err := tx.Exec("INSERT INTO Tasks (NoteId, Line, Done) VALUES (?, ?, ?);", noteId, task.Line, task.Done); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OpenTasks returns the unchecked items of all days, ordered by project name
+// and then by note date
+func (s *Store) OpenTasks() ([]Task, error) {
+	rows, err := s.conn.Query(noteQuery + "WHERE n.Id IN (SELECT NoteId FROM Tasks WHERE Done = 0) ORDER BY n.CreatedAt;")
+	if err != nil {
+		return nil, err
+	}
+	notes, err := s.scanNotes(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var open []Task
+	for _, note := range notes {
+		for _, task := range parseTasks(note.Body) {
+			if !task.Done {
+				task.Note = note
+				open = append(open, task)
+			}
+		}
+	}
+	sort.SliceStable(open, func(i, j int) bool {
+		return strings.ToLower(open[i].Note.Project.Name) < strings.ToLower(open[j].Note.Project.Name)
+	})
+	return open, nil
+}
+
+// noteTasks returns the checklist items of the note
+func noteTasks(note Note) []Task {
+	tasks := parseTasks(note.Body)
+	for i := range tasks {
+		tasks[i].Note = note
+	}
+	return tasks
+}
+
+// toggleNoteTask checks or unchecks the item in the body of its note and saves
+// the note
+func (m *model) toggleNoteTask(task Task) error {
+	note, err := m.store.GetNoteById(task.Note.Id)
+	if err != nil {
+		return err
+	}
+	if note.Id == "" {
+		return fmt.Errorf("note %q is gone", task.Note.Title)
+	}
+	// the list may be older than the note, so the line must still be the item
+	current := false
+	for _, t := range parseTasks(note.Body) {
+		if t.Line == task.Line && t.Text == task.Text {
+			current = true
+			break
+		}
+	}
+	if !current {
+		return fmt.Errorf("%q changed in note %q, reopen the list", task.Text, note.Title)
+	}
+	if note.Body, err = toggleTask(note.Body, task.Line); err != nil {
+		return err
+	}
+	if err := m.store.SaveNoteWithProject(note, note.Project.Id, note.Category.Id, note.CreatedAt); err != nil {
+		return err
+	}
+
+	notes, err := loadNotes(m.store, m.currentDate)
+	if err != nil {
+		return err
+	}
+	m.setNotes(notes)
+	return nil
+}
+
+// showNoteTasks opens the checklist of the note in the detail view
+func (m *model) showNoteTasks(note Note) {
+	tasks := noteTasks(note)
+	if len(tasks) == 0 {
+		m.statusMsg = "no checklist items in this note"
+		return
+	}
+	m.tasks = tasks
+	m.taskCursor = 0
+	m.statusMsg = ""
+	m.state = noteTasksView
+}
+
+// showOpenTasks opens the unchecked items of all days
+func (m *model) showOpenTasks() error {
+	store, err := m.sqlite()
+	if err != nil {
+		return err
+	}
+	tasks, err := store.OpenTasks()
+	if err != nil {
+		return err
+	}
+	m.tasks = tasks
+	if m.taskCursor >= len(tasks) {
+		m.taskCursor = max(len(tasks)-1, 0)
+	}
+	m.state = openTasksView
+	return nil
+}
+
+// reloadTasks lists the items again after a toggle, keeping the cursor
+func (m *model) reloadTasks() error {
+	if m.state == openTasksView {
+		return m.showOpenTasks()
+	}
+	note, err := m.store.GetNoteById(m.tasks[0].Note.Id)
+	if err != nil {
+		return err
+	}
+	m.tasks = noteTasks(note)
+	if m.taskCursor >= len(m.tasks) {
+		m.taskCursor = max(len(m.tasks)-1, 0)
+	}
+	return nil
+}
+
+// goToNote goes to the date of the note, selects it and shows it
+func (m *model) goToNote(target Note) error {
+	date := target.CreatedAt.UTC().Truncate(24 * time.Hour)
+	notes, err := loadNotes(m.store, date)
+	if err != nil {
+		return err
+	}
+	m.currentDate = date
+	m.setNotes(notes)
+	for index, note := range m.notes {
+		if note.Id == target.Id {
+			m.listIndex = index
+			m.noteTable.SetCursor(index)
+			return m.showNote(note)
+		}
+	}
+	return fmt.Errorf("note %q is gone", target.Title)
+}
+
+func (m model) updateTasks(key string) (model, tea.Cmd) {
+	switch key {
+	case "esc":
+		if m.state == noteTasksView {
+			if note, ok := m.selectedNote(); ok {
+				if err := m.showNote(note); err != nil {
+					m.statusMsg = "error: " + err.Error()
+				}
+			}
+			m.state = noteDetailView
+		} else {
+			m.state = listView
+		}
+		m.statusMsg = ""
+	case "up", "k":
+		if m.taskCursor > 0 {
+			m.taskCursor--
+		}
+	case "down", "j":
+		if m.taskCursor < len(m.tasks)-1 {
+			m.taskCursor++
+		}
+	case " ", "x": // Check or uncheck the item
+		if m.taskCursor >= len(m.tasks) {
+			break
+		}
+		task := m.tasks[m.taskCursor]
+		if err := m.toggleNoteTask(task); err != nil {
+			m.statusMsg = "error: " + err.Error()
+			break
+		}
+		if err := m.reloadTasks(); err != nil {
+			m.statusMsg = "error: " + err.Error()
+			break
+		}
+		m.statusMsg = fmt.Sprintf("checked %q", task.Text)
+		if task.Done {
+			m.statusMsg = fmt.Sprintf("unchecked %q", task.Text)
+		}
+	case "enter": // Go to the note of the item
+		if m.state == openTasksView && m.taskCursor < len(m.tasks) {
+			if err := m.goToNote(m.tasks[m.taskCursor].Note); err != nil {
+				m.statusMsg = "error: " + err.Error()
+				break
+			}
+			m.state = noteDetailView
+		}
+	}
+	return m, nil
+}
+
+func (m model) tasksView() string {
+	s := strings.Builder{}
+	help := "space - check, esc - back"
+
+	if m.state == noteTasksView {
+		note, _ := m.selectedNote()
+		s.WriteString(editTitleNoteStyle.Render("Checklist of "+note.Title) + "\n\n")
+	} else {
+		s.WriteString("Open tasks:\n")
+		help = "space - check, enter - go to note, esc - back"
+		if len(m.tasks) == 0 {
+			s.WriteString("\n" + faintStyle.Render("Nothing open, add - [ ] items to your notes.") + "\n")
+		}
+	}
+
+	project := ""
+	for i, task := range m.tasks {
+		if m.state == openTasksView && (i == 0 || task.Note.Project.Name != project) {
+			project = task.Note.Project.Name
+			s.WriteString("\n" + enumeratorStyle.Render(project) + "\n")
+		}
+
+		prefix := " "
+		if i == m.taskCursor {
+			prefix = ">"
+		}
+		box := "[ ]"
+		if task.Done {
+			box = "[x]"
+		}
+		s.WriteString(enumeratorStyle.Render(prefix) + box + " " + task.Text)
+		if m.state == openTasksView {
+			s.WriteString(" " + faintStyle.Render(task.Note.CreatedAt.Format(dateLayout)+" · "+task.Note.Title))
+		}
+		s.WriteString("\n")
+	}
+	s.WriteString("\n")
+
+	if m.statusMsg != "" {
+		s.WriteString(statusStyle.Render(m.statusMsg) + "\n\n")
+	}
+	return s.String() + faintStyle.Render(help)
+}
@@ -0,0 +1,205 @@
+package tui
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Template is a named body skeleton with a default project and category.
+// The body may use {{date}}, {{weekday}}, {{title}}, {{project}} and {{category}}.
+type Template struct {
+	Id       int
+	Name     string
+	Body     string
+	Project  Project
+	Category Category
+}
+
+func (t Template) expand(title string, date time.Time) string {
+	r := strings.NewReplacer(
+		"{{date}}", date.Format(dateLayout),
+		"{{weekday}}", date.Format("Monday"),
+		"{{title}}", title,
+		"{{project}}", t.Project.Name,
+		"{{category}}", t.Category.Name,
+	)
+	return r.Replace(t.Body)
+}
+
+func (s *Store) initTemplates() error {
+	var exists int
+	err := s.conn.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'Templates';").Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	createTableTemplatesStmt := `
+        CREATE TABLE IF NOT EXISTS Templates (
+            Id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+            Name TEXT NOT NULL UNIQUE,
+            Body TEXT NOT NULL,
+            ProjectId INTEGER,
+            CategoryId INTEGER,
+            FOREIGN KEY (ProjectId) REFERENCES Projects(Id),
+            FOREIGN KEY (CategoryId) REFERENCES Categories(Id)
+        );`
+
+	if _, err := s.conn.Exec(createTableTemplatesStmt); err != nil {
+		return err
+	}
+	if exists != 0 {
+		return nil
+	}
+
+	// Insert mock templates into a new table, names may be encrypted later
+	mockTemplates := []struct {
+		name, body, project, category string
+	}{
+		{
+			name:     "Bug investigation",
+			body:     "# {{title}}\n\n## Symptoms\n\n## Steps to reproduce\n\n## Findings\n\n## Next steps\n",
+			project:  "Work",
+			category: "Urgent",
+		},
+		{
+			name:     "Meeting",
+			body:     "# {{title}} ({{date}})\n\n## Attendees\n\n## Notes\n\n## Action items\n- [ ] \n",
+			project:  "Work",
+			category: "Important",
+		},
+		{
+			name:     "Code review",
+			body:     "# Review: {{title}}\n\n## Summary\n\n## Comments\n\n## Verdict\n",
+			project:  "Work",
+			category: "Important",
+		},
+	}
+
+	for _, t := range mockTemplates {
+		query := `
+            INSERT OR IGNORE INTO Templates (Name, Body, ProjectId, CategoryId)
+            VALUES (?, ?,
+                (SELECT Id FROM Projects WHERE Name = ?),
+                (SELECT Id FROM Categories WHERE Name = ?));`
+		if _, err := s.conn.Exec(query, t.name, t.body, t.project, t.category); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) GetTemplates() ([]Template, error) {
+	query := `
+        SELECT
+            t.Id, t.Name, t.Body,
+            COALESCE(p.Id, 0), COALESCE(p.Name, ''), COALESCE(p.Description, ''),
+            COALESCE(c.Id, 0), COALESCE(c.Name, '')
+        FROM Templates t
+        LEFT JOIN Projects p ON t.ProjectId = p.Id
+        LEFT JOIN Categories c ON t.CategoryId = c.Id;
+    `
+
+	rows, err := s.conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []Template
+	for rows.Next() {
+		var t Template
+		if err := rows.Scan(
+			&t.Id, &t.Name, &t.Body,
+			&t.Project.Id, &t.Project.Name, &t.Project.Description,
+			&t.Category.Id, &t.Category.Name,
+		); err != nil {
+			return nil, err
+		}
+		// names and bodies are copied from notes, they are encrypted like them
+		if t.Name, err = s.open(t.Name, rowId("template", t.Id), "Name"); err != nil {
+			return nil, err
+		}
+		if t.Body, err = s.open(t.Body, rowId("template", t.Id), "Body"); err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, nil
+}
+
+// SaveTemplate creates the template or replaces the one with the same name.
+// Encrypted names differ every time they are sealed, so the template to
+// replace is looked up by its decrypted name.
+func (s *Store) SaveTemplate(t Template) error {
+	if s.Locked() {
+		return ErrLocked
+	}
+
+	templates, err := s.GetTemplates()
+	if err != nil {
+		return err
+	}
+	t.Id = 0
+	for _, existing := range templates {
+		if existing.Name == t.Name {
+			t.Id = existing.Id
+		}
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if t.Id == 0 {
+		// the id is part of the encryption, the row is made first
+		result, err := tx.Exec("INSERT INTO Templates (Name, Body) VALUES (?, '');", "new:"+uuid.New().String())
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		t.Id = int(id)
+	}
+
+	name, err := s.seal(t.Name, rowId("template", t.Id), "Name")
+	if err != nil {
+		return err
+	}
+	body, err := s.seal(t.Body, rowId("template", t.Id), "Body")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE Templates SET Name = ?, Body = ?, ProjectId = ?, CategoryId = ? WHERE Id = ?;",
+		name, body, nullableId(t.Project.Id), nullableId(t.Category.Id), t.Id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) DeleteTemplate(id int) error {
+	_, err := s.conn.Exec("DELETE FROM Templates WHERE Id = ?", id)
+	return err
+}
+
+// nullableId stores 0 as NULL
+func nullableId(id int) any {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
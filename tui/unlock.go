@@ -0,0 +1,94 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var errCancelled = errors.New("cancelled")
+
+// passphraseModel asks for a passphrase until check accepts it
+type passphraseModel struct {
+	title     string
+	input     textinput.Model
+	check     func(string) error
+	err       error
+	accepted  bool
+	cancelled bool
+}
+
+func (m passphraseModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m passphraseModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			m.cancelled = true
+			return m, tea.Quit
+		case "enter":
+			if err := m.check(m.input.Value()); err != nil {
+				m.err = err
+				m.input.SetValue("")
+				return m, nil
+			}
+			m.accepted = true
+			return m, tea.Quit
+		}
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+func (m passphraseModel) View() string {
+	if m.accepted || m.cancelled {
+		return ""
+	}
+
+	s := m.title + "\n\n" + m.input.View() + "\n\n"
+	if m.err != nil {
+		s += warningStyle.Render(m.err.Error()) + "\n\n"
+	}
+	return s + "enter: confirm - esc: cancel\n"
+}
+
+// PromptPassphrase asks for a passphrase in the terminal, check may reject
+// it with an error that is shown before asking again
+func PromptPassphrase(title string, check func(string) error) (string, error) {
+	input := textinput.New()
+	input.Placeholder = "passphrase"
+	input.EchoMode = textinput.EchoPassword
+	input.EchoCharacter = '•'
+	input.Focus()
+
+	if check == nil {
+		check = func(string) error { return nil }
+	}
+
+	result, err := tea.NewProgram(passphraseModel{title: title, input: input, check: check}).Run()
+	if err != nil {
+		return "", err
+	}
+	m := result.(passphraseModel)
+	if !m.accepted {
+		return "", errCancelled
+	}
+	return m.input.Value(), nil
+}
+
+// UnlockPrompt asks for the passphrase of an encrypted store until it is
+// right, unencrypted stores are left alone
+func UnlockPrompt(store *Store) error {
+	if !store.Locked() {
+		return nil
+	}
+
+	_, err := PromptPassphrase(fmt.Sprintf("%s is encrypted, enter the passphrase", store.Path), store.Unlock)
+	return err
+}
@@ -17,11 +17,24 @@ var (
 	editNoteStyle      = lipgloss.NewStyle().Background(lipgloss.Color("98")).Padding(0, 1)
 	editTitleNoteStyle = lipgloss.NewStyle().Background(lipgloss.Color("95")).Padding(0, 1)
 	currentDateStyle = lipgloss.NewStyle().Background(lipgloss.Color("75")).Padding(0, 1)
+	statusStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("170"))
+	warningStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+
+	targetStyles = map[uint]lipgloss.Style{
+		underTarget: lipgloss.NewStyle().Foreground(lipgloss.Color("214")),
+		onTarget:    lipgloss.NewStyle().Foreground(lipgloss.Color("42")),
+		overTarget:  lipgloss.NewStyle().Foreground(lipgloss.Color("196")),
+	}
+	targetLabels = map[uint]string{
+		underTarget: "under target",
+		onTarget:    "on target",
+		overTarget:  "over target",
+	}
 )
 
 func (m model) View() string {
 	header := appNameStyle.Render("NOTES APP") + "\n\n"
-	headerCurrentDate := currentDateStyle.Render(m.currentDate.Format("Mon") + ", " +m.currentDate.Format("02 Jan 2006")) + "\n\n"
+	headerCurrentDate := currentDateStyle.Render(m.currentDate.Format("Mon") + ", " +m.currentDate.Format("02 Jan 2006")) + " " + m.targetView() + "\n\n"
 
 	if m.isLoading {
 		return header +
@@ -49,6 +62,9 @@ func (m model) View() string {
 			}
 
             s.WriteString(m.projects[i].Name)
+			if status, ok := m.budgetStatus(m.projects[i].Id); ok && status.Over() {
+				s.WriteString(" " + warningStyle.Render("⚠ over budget "+status.String()))
+			}
             s.WriteString("\n")
 		}
 
@@ -69,8 +85,114 @@ func (m model) View() string {
             s.WriteString("\n")
 		}
 
+		if m.statusMsg != "" {
+			s.WriteString("\n" + statusStyle.Render(m.statusMsg) + "\n")
+		}
+
 		return header + s.String() + "\n" + faintStyle.Render("ctrl+s - save, esc - quit")
 
+	case recurrenceView:
+		s := strings.Builder{}
+		s.WriteString("Recurring notes:\n\n")
+
+		if len(m.recurrences) == 0 {
+			s.WriteString(faintStyle.Render("No recurring notes yet.") + "\n")
+		}
+		for i, r := range m.recurrences {
+			prefix := " "
+			if i == m.recurrenceCursor {
+				prefix = ">"
+			}
+			s.WriteString(enumeratorStyle.Render(prefix) + r.Title + " | " + faintStyle.Render(r.Rule+" | "+r.Project.Name) + "\n")
+		}
+		s.WriteString("\n")
+
+		if m.isAddingRule {
+			note, _ := m.selectedNote()
+			s.WriteString(fmt.Sprintf("Repeat %q with rule (daily, weekdays, weekly, monthly or FREQ=...):\n\n", note.Title))
+			s.WriteString(m.ruleInput.View() + "\n\n")
+			if m.ruleErr != nil {
+				s.WriteString(m.ruleErr.Error() + "\n\n")
+			}
+			return header + s.String() + faintStyle.Render("enter - save, esc - cancel")
+		}
+
+		return header + s.String() + faintStyle.Render("a - repeat selected note, d - delete, esc - back")
+
+	case budgetView:
+		s := strings.Builder{}
+		s.WriteString("Budgets on " + m.currentDate.Format("02 Jan 2006") + ":\n\n")
+
+		if len(m.budgetStatuses) == 0 {
+			s.WriteString(faintStyle.Render("No budgets yet, set one with: notes budget set -project <name> -hours <n> -per week") + "\n")
+		}
+		for _, status := range m.budgetStatuses {
+			percent := status.Percent()
+			if percent > 1 {
+				percent = 1
+			}
+
+			s.WriteString(fmt.Sprintf("%-12s %s %s", status.Project.Name, m.progressBar.ViewAs(percent), status.String()))
+			switch {
+			case !status.Active:
+				s.WriteString(" " + faintStyle.Render("(inactive)"))
+			case status.Over():
+				s.WriteString(" " + warningStyle.Render("⚠ over budget"))
+			}
+			s.WriteString("\n" + faintStyle.Render(fmt.Sprintf("%-12s %s", "", status.PeriodLabel())) + "\n\n")
+		}
+
+		return header + s.String() + faintStyle.Render("esc - back")
+
+	case templateSelectView:
+		s := strings.Builder{}
+		s.WriteString("New note from template:\n\n")
+
+		if len(m.templates) == 0 {
+			s.WriteString(faintStyle.Render("No templates yet, save one with T from the list.") + "\n")
+		}
+		for i, t := range m.templates {
+			if m.templateCursor == i {
+				s.WriteString("(•) ")
+			} else {
+				s.WriteString("( ) ")
+			}
+			s.WriteString(t.Name + " " + faintStyle.Render(t.Project.Name+" / "+t.Category.Name) + "\n")
+		}
+
+		return header + s.String() + "\n" + faintStyle.Render("enter - use, d - delete, esc - back")
+
+	case dateSelectView:
+		action := "Move"
+		if m.dateAction == dateActionCopy {
+			action = "Copy"
+		}
+		note, _ := m.selectedNote()
+
+		s := fmt.Sprintf("%s %q to date:\n\n%s\n\n", action, note.Title, m.dateInput.View())
+		if m.dateErr != nil {
+			s += m.dateErr.Error() + "\n\n"
+		}
+		return header + s + faintStyle.Render("enter - confirm, esc - cancel")
+
+	case conflictView:
+		return header + m.conflictView()
+
+	case pomodoroView:
+		return m.pomodoroView()
+
+	case attachmentsView, attachPickView:
+		return header + m.attachmentsView()
+
+	case noteTasksView, openTasksView:
+		return header + m.tasksView()
+
+	case mergeView:
+		return header +
+			"Merge the bodies of " + editTitleNoteStyle.Render(m.conflict.Mine.Title) + ":\n\n" +
+			m.textArea.View() + "\n\n" +
+			faintStyle.Render("ctrl+s - save, esc - back")
+
 	case titleView:
 		return header +
 			"Note title:\n\n" +
@@ -82,44 +204,79 @@ func (m model) View() string {
 			editTitleNoteStyle.Render(m.currNote.Title) + "\n\n" +
 			m.textArea.View() + "\n\n"
 
-		if m.isEditing {
+		if m.isEditing && !m.currNote.CreatedAt.IsZero() {
 			noteDetails += faintStyle.Render("Created At: ") + faintStyle.Render(m.currNote.CreatedAt.Format("2006-01-02 15:04:05")) + "\n" +
 				faintStyle.Render("Updated At: ") + faintStyle.Render(m.currNote.UpdatedAt.Format("2006-01-02 15:04:05")) + "\n"
 		}
 
 		return header + noteDetails + faintStyle.Render("tab - next, esc - discard")
 
-    case summaryNoteToday:
-        return m.summaryNoteViewport.View()
-
-	case listView:
-		var notesList string
-		for i, n := range m.notes {
-			prefix := " "
-			if i == m.listIndex {
-				prefix = ">"
+    case summaryNoteToday, standupView, noteDetailView:
+		status := ""
+		if m.statusMsg != "" {
+			status = statusStyle.Render(m.statusMsg) + "\n"
+		}
+		help := "y - copy, esc - back"
+		if m.state == noteDetailView {
+			help = "y - copy, e - edit, x - checklist, o - attachments, esc - back"
+			if len(m.links) > 0 {
+				help = "1-9 - follow link, " + help
 			}
+		}
+		return m.summaryNoteViewport.View() + "\n" + status + faintStyle.Render(help)
 
-			shortBody := strings.ReplaceAll(n.Body, "\n", " ")
-			if len(shortBody) > 30 {
-				shortBody = shortBody[:30] + "..." // Add ellipsis for truncated body
-			}
+	case listView:
+		notesList := m.noteTable.View() + "\n\n"
 
-			notesList += enumeratorStyle.Render(prefix) + n.Title + " | " + faintStyle.Render(shortBody) + "\n\n"
-		}
 		// Conditionally add the "d - delete" option if there is more than one note
 		deleteOption := ""
 		if len(m.notes) >= 1 {
 			deleteOption = faintStyle.Render("d - delete") + ", "
 		}
 
-		newNoteOption := faintStyle.Render("n - new note") + ", "
+		newNoteOption := faintStyle.Render("n - new note, t - from template") + ", "
+		sortOption := faintStyle.Render("s - sort ("+sortNames[m.sortBy]+")") + ", "
+		if len(m.notes) >= 1 {
+			sortOption += faintStyle.Render("v - view, m - move, c - copy, b - billable, p - pomodoro, T - save as template") + ", "
+		}
+		copyDayOption := faintStyle.Render("Y - copy previous day, R - recurring, u - standup, B - budgets, O - open tasks") + ", "
+		if note, ok := m.selectedNote(); ok && note.isPlaceholder() {
+			copyDayOption += faintStyle.Render("a - accept, x - skip") + ", "
+		} else if ok && note.Draft {
+			copyDayOption += faintStyle.Render("a - accept draft") + ", "
+		}
+
+		status := ""
+		if m.carryCount > 0 {
+			status = statusStyle.Render(fmt.Sprintf("%d open items on %s, C - carry them over", m.carryCount, m.carryFrom.Format("Mon 02 Jan"))) + "\n\n"
+		}
+		if m.statusMsg != "" {
+			status += statusStyle.Render(m.statusMsg) + "\n\n"
+		}
 		nextDayOption := faintStyle.Render("ctrl+n - next day") + ", "
 		prevDayOption := faintStyle.Render("ctrl+p - previous day")
 		exitCliOption := faintStyle.Render("q - quit") + ", "
 
-		return header + headerCurrentDate + notesList + newNoteOption + deleteOption + exitCliOption + nextDayOption + prevDayOption
+		return header + headerCurrentDate + notesList + status + newNoteOption + deleteOption + sortOption + copyDayOption + exitCliOption + nextDayOption + prevDayOption
 	}
 
 	return header // Fallback to header if no state matches
 }
+
+// targetView shows the time logged on currentDate against the daily target
+func (m model) targetView() string {
+	logged := loggedTime(m.notes)
+	target := m.config.TargetFor(m.currentDate)
+	if target == 0 {
+		return faintStyle.Render(formatDuration(logged) + " logged, no target")
+	}
+
+	percent := float64(logged) / float64(target)
+	if percent > 1 {
+		percent = 1
+	}
+
+	status := targetStatus(logged, target)
+	return m.progressBar.ViewAs(percent) + " " +
+		targetStyles[status].Render(formatDuration(logged)+" / "+formatDuration(target)+" "+targetLabels[status])
+}
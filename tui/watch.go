@@ -0,0 +1,71 @@
+package tui
+
+import (
+	"context"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// pollInterval is how often the list checks for changes of other processes
+const pollInterval = 2 * time.Second
+
+// changeWatcher is implemented by backends that can tell when the data was
+// changed, the version changes with every commit
+type changeWatcher interface {
+	DataVersion() (int64, error)
+}
+
+type dataVersionMsg struct {
+	version int64
+	err     error
+}
+
+// notesChangedMsg carries the notes of date reloaded after a change
+type notesChangedMsg struct {
+	date  time.Time
+	notes []Note
+}
+
+// DataVersion returns SQLite's data_version, it changes when another
+// connection, from this or another process, commits a change
+func (s *Store) DataVersion() (int64, error) {
+	ctx := context.Background()
+	if s.watch == nil {
+		// data_version is per connection, so always ask the same one
+		conn, err := s.conn.Conn(ctx)
+		if err != nil {
+			return 0, err
+		}
+		s.watch = conn
+	}
+
+	var version int64
+	err := s.watch.QueryRowContext(ctx, "PRAGMA data_version;").Scan(&version)
+	return version, err
+}
+
+// pollChanges checks the data version after pollInterval
+func (m model) pollChanges() tea.Cmd {
+	watcher, ok := m.store.(changeWatcher)
+	if !ok {
+		return nil
+	}
+
+	return tea.Tick(pollInterval, func(time.Time) tea.Msg {
+		version, err := watcher.DataVersion()
+		return dataVersionMsg{version: version, err: err}
+	})
+}
+
+// reloadChanged loads the notes of the shown day again
+func (m model) reloadChanged() tea.Cmd {
+	date := m.currentDate
+	return func() tea.Msg {
+		notes, err := loadNotes(m.store, date)
+		if err != nil {
+			return errMsg{err}
+		}
+		return notesChangedMsg{date: date, notes: notes}
+	}
+}